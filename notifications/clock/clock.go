@@ -0,0 +1,110 @@
+// Package clock abstrae el tiempo para que los workers (scheduler, outbox, email, webhooks) y los
+// límites de tasa puedan probarse con un reloj manual
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock es la fuente de tiempo y de tickers de los workers
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker es el subconjunto de time.Ticker que usan los workers
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real usa el reloj del sistema
+type Real struct{}
+
+func (Real) Now() time.Time { return time.Now() }
+
+func (Real) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ t *time.Ticker }
+
+func (r realTicker) C() <-chan time.Time { return r.t.C }
+
+func (r realTicker) Stop() { r.t.Stop() }
+
+// Fake es un reloj manual para tests: solo avanza con Set o Advance, y sus tickers disparan
+// cuando el reloj cruza su siguiente tick. Como time.Ticker, un tick no leído se descarta.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFake crea un reloj falso parado en now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (c *Fake) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set mueve el reloj a t y dispara los tickers vencidos
+func (c *Fake) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+	for _, ticker := range c.tickers {
+		ticker.fire(t)
+	}
+}
+
+// Advance adelanta el reloj d
+func (c *Fake) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+func (c *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for clock.Fake.NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ticker := &fakeTicker{ch: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, ticker)
+	return ticker
+}
+
+type fakeTicker struct {
+	mu      sync.Mutex
+	ch      chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTicker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+}
+
+// fire envía un único tick si now alcanzó el siguiente y reprograma el próximo después de now
+func (t *fakeTicker) fire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped || now.Before(t.next) {
+		return
+	}
+	select {
+	case t.ch <- now:
+	default:
+	}
+	for !t.next.After(now) {
+		t.next = t.next.Add(t.period)
+	}
+}
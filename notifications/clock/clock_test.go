@@ -0,0 +1,46 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeTicker(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	fake := NewFake(start)
+	ticker := fake.NewTicker(time.Minute)
+
+	fake.Advance(59 * time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker fired before its interval")
+	default:
+	}
+
+	fake.Advance(time.Second)
+	select {
+	case tick := <-ticker.C():
+		if !tick.Equal(start.Add(time.Minute)) {
+			t.Errorf("tick = %v, want %v", tick, start.Add(time.Minute))
+		}
+	default:
+		t.Fatal("ticker did not fire at its interval")
+	}
+
+	// Un salto largo entrega un solo tick, como time.Ticker con un lector lento
+	fake.Advance(5 * time.Minute)
+	<-ticker.C()
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker queued more than one tick")
+	default:
+	}
+
+	ticker.Stop()
+	fake.Advance(time.Hour)
+	select {
+	case <-ticker.C():
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+}
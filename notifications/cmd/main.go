@@ -1,26 +1,175 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"notifications/clock"
 	"notifications/config"
+	"notifications/email"
 	"notifications/grpc"
 	"notifications/handlers"
+	"notifications/health"
+	"notifications/logger"
+	"notifications/metrics"
+	"notifications/middleware"
+	"notifications/migrations"
+	"notifications/models"
+	"notifications/outbox"
+	"notifications/push"
+	"notifications/ratelimit"
+	"notifications/scheduler"
+	"notifications/tracing"
+	"notifications/webhooks"
+	"os"
+	"time"
+	_ "time/tzdata" // zonas horarias de las preferencias sin depender de la imagen
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func main() {
 	config.LoadEnv()
+	logger.Setup()
+
+	// Trazas OpenTelemetry con propagación W3C; el exportador se elige con OTEL_TRACES_EXPORTER
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		logger.Fatal("Failed to set up tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	config.ConnectDatabase()
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrateCommand(os.Args[2:])
+		return
+	}
+
+	// Opcional: aplicar migraciones al arrancar (el advisory lock evita que dos réplicas las apliquen a la vez)
+	if config.GetBoolEnv("MIGRATE_ON_STARTUP", false) {
+		if err := migrations.Up(config.DB); err != nil {
+			logger.Fatal("Failed to apply migrations", "error", err)
+		}
+	}
+
+	// Límites de ingesta y por destinatario: en memoria por réplica o compartidos en Postgres
+	handlers.RateLimits = rateLimitStore()
+
+	// Comprobaciones de /livez y /readyz (esta también la usa grpc.health.v1). /livez solo mira el
+	// proceso: un worker parado saca la réplica del tráfico pero no hace que la reinicien en bucle.
+	handlers.LivenessChecks = map[string]health.Check{"process": health.Process}
+	handlers.ReadinessChecks = map[string]health.Check{
+		"database": handlers.DatabaseCheck,
+		"grpc":     grpc.ListenerCheck,
+		"pubsub":   handlers.PubSubCheck,
+		"workers":  health.Workers,
+	}
+
 	go grpc.StartGRPCServer()
-	r := gin.Default()
+	go handlers.PurgeExpiredIdempotencyKeys(time.Hour)
+	go middleware.PurgeExpiredWebhookNonces(10 * time.Minute)
+
+	// Canal push (FCM para android/web, APNs para ios) según la configuración disponible
+	if providers := pushProviders(); len(providers) > 0 {
+		handlers.Push = &push.Service{
+			DB:        config.DB,
+			Providers: providers,
+			Retry: push.Retry{
+				Attempts: config.GetIntEnv("PUSH_RETRY_ATTEMPTS", 3),
+				Backoff:  config.GetDurationEnv("PUSH_RETRY_BACKOFF", time.Second),
+			},
+			// Por debajo del lease de 2 min del outbox para que el evento no se publique dos veces
+			Timeout: config.GetDurationEnv("PUSH_TIMEOUT", time.Minute),
+		}
+	}
+
+	// Outbox: entrega por canal tras el commit, con recuperación si el proceso muere entre medias
+	handlers.Relay = &outbox.Relay{
+		DB:          config.DB,
+		Publishers:  handlers.OutboxPublishers(),
+		Clock:       clock.Real{},
+		Interval:    config.GetDurationEnv("OUTBOX_RELAY_INTERVAL", 5*time.Second),
+		MaxAttempts: config.GetIntEnv("OUTBOX_MAX_ATTEMPTS", 5),
+		Backoff:     config.GetDurationEnv("OUTBOX_BACKOFF", 5*time.Second),
+		BatchSize:   200,
+		Retention:   config.GetDurationEnv("OUTBOX_RETENTION", 7*24*time.Hour),
+		// Las entregas WebSocket de usuarios conectados a otra réplica se encaminan a ella, que se
+		// despierta por LISTEN/NOTIFY
+		Replica:      handlers.ReplicaID,
+		RouteTimeout: config.GetDurationEnv("OUTBOX_ROUTE_TIMEOUT", 30*time.Second),
+		Wake:         outbox.Listen(context.Background(), config.DB, handlers.ReplicaID),
+	}
+	handlers.Relay.Heartbeat = health.NewHeartbeat("outbox_relay", handlers.Relay.Interval)
+	metrics.QueueDepth("outbox", handlers.Relay.Pending)
+	go handlers.Relay.Run(context.Background())
+
+	// Resúmenes de horario de silencio (persistidos en Postgres, sobreviven a reinicios)
+	digests := scheduler.New(config.DB, scheduler.SinkFunc(handlers.ReleaseDigest),
+		config.GetDurationEnv("DIGEST_INTERVAL", time.Minute))
+	digests.Heartbeat = health.NewHeartbeat("digest_scheduler", digests.Interval)
+	go digests.Run(context.Background())
+
+	// Canal email para usuarios desconectados (solo si hay SMTP configurado)
+	if smtpHost := config.GetEnv("SMTP_HOST", ""); smtpHost != "" {
+		templates, err := email.LoadTemplates()
+		if err != nil {
+			logger.Fatal("Failed to load email templates", "error", err)
+		}
+		worker := &email.Worker{
+			DB: config.DB,
+			Sender: &email.SMTPSender{
+				Addr:     net.JoinHostPort(smtpHost, config.GetEnv("SMTP_PORT", "587")),
+				Username: config.GetEnv("SMTP_USERNAME", ""),
+				Password: config.GetEnv("SMTP_PASSWORD", ""),
+				From:     config.GetEnv("SMTP_FROM", "notifications@localhost"),
+				Timeout:  config.GetDurationEnv("SMTP_TIMEOUT", 30*time.Second),
+			},
+			Templates:        templates,
+			Clock:            clock.Real{},
+			Interval:         config.GetDurationEnv("EMAIL_SCAN_INTERVAL", time.Minute),
+			OfflineDelay:     config.GetDurationEnv("EMAIL_OFFLINE_DELAY", 15*time.Minute),
+			MaxAge:           config.GetDurationEnv("EMAIL_MAX_AGE", 24*time.Hour),
+			RateLimitPerHour: config.GetIntEnv("EMAIL_RATE_LIMIT_PER_HOUR", 10),
+			Retry:            email.Retry{Attempts: 3, Backoff: 2 * time.Second},
+			BatchSize:        200,
+			Lease:            config.GetDurationEnv("EMAIL_SENDING_LEASE", 10*time.Minute),
+		}
+		worker.Heartbeat = health.NewHeartbeat("email_worker", worker.Interval)
+		metrics.QueueDepth("email", worker.Pending)
+		go worker.Run(context.Background())
+	}
+
+	// Webhooks salientes hacia suscriptores de terceros
+	dispatcher := &webhooks.Dispatcher{
+		DB:          config.DB,
+		Client:      webhooks.NewClient(config.GetDurationEnv("WEBHOOK_TIMEOUT", 10*time.Second), handlers.WebhookAllowInsecureTargets()),
+		Clock:       clock.Real{},
+		Interval:    config.GetDurationEnv("WEBHOOK_DISPATCH_INTERVAL", 5*time.Second),
+		MaxAttempts: config.GetIntEnv("WEBHOOK_MAX_ATTEMPTS", 8),
+		BackoffBase: config.GetDurationEnv("WEBHOOK_BACKOFF_BASE", 30*time.Second),
+		BackoffMax:  config.GetDurationEnv("WEBHOOK_BACKOFF_MAX", 6*time.Hour),
+		BatchSize:   100,
+	}
+	dispatcher.Heartbeat = health.NewHeartbeat("webhook_dispatcher", dispatcher.Interval)
+	metrics.QueueDepth("webhook_deliveries", dispatcher.Due)
+	go dispatcher.Run(context.Background())
+
+	// Span por petición (traceparent W3C) y logs de acceso estructurados con request ID en lugar del logger de texto de Gin
+	r := gin.New()
+	r.Use(gin.Recovery(),
+		otelgin.Middleware(tracing.ServiceName, otelgin.WithFilter(func(r *http.Request) bool { return !middleware.IsProbeRoute(r.URL.Path) })),
+		middleware.RequestLogger(),
+		middleware.HTTPMetrics(),
+	)
 
 	// CORS libre con soporte para WebSockets
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, Sec-WebSocket-Protocol, Sec-WebSocket-Key, Sec-WebSocket-Version, Upgrade, Connection")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, Idempotency-Key, traceparent, tracestate, Sec-WebSocket-Protocol, Sec-WebSocket-Key, Sec-WebSocket-Version, Upgrade, Connection")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(200)
@@ -33,21 +182,106 @@ func main() {
 		c.JSON(200, gin.H{"message": "pong"})
 	})
 
-	// Ruta health
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	// Liveness (workers) y readiness (base de datos, gRPC, pub/sub y workers) con detalle por comprobación;
+	// /health se mantiene como alias de /readyz
+	r.GET("/livez", handlers.Livez)
+	r.GET("/readyz", handlers.Readyz)
+	r.GET("/health", handlers.Readyz)
+
+	// Métricas Prometheus; con METRICS_TOKEN exige "Authorization: Bearer <token>"
+	r.GET("/metrics", gin.WrapH(metrics.Handler(config.GetEnv("METRICS_TOKEN", ""))))
 
 	// Webhook Likes
-	r.POST("/webhook/like", handlers.WebhookLike)
+	ingestLimit := middleware.RateLimit(handlers.RateLimits, handlers.IngestLimit())
+	r.POST("/webhook/like", middleware.VerifyWebhookSignature("like"), ingestLimit, handlers.WebhookLike)
+
+	// Webhooks genéricos: despacho por el campo event (like.created, comment.created, ...)
+	r.POST("/webhook/:source", middleware.VerifyWebhookSignature(""), ingestLimit, handlers.Webhook)
+
+	// Rutas de usuario: todas exigen JWT (RequireAuth responde 401 antes de llegar al handler)
+	authed := r.Group("", middleware.RequireAuth())
 
 	// WEBSOCKET
-	r.GET("/ws", handlers.WsHandler)
+	authed.GET("/ws", handlers.WsHandler)
 
 	// Endpoints
-	r.GET("/notifications/:userId", handlers.GetNotifications)
-	r.PUT("/notifications/:notificationId/read", handlers.MarkNotificationAsRead)
+	authed.GET("/notifications/:userId", handlers.GetNotifications)
+	authed.PUT("/notifications/:notificationId/read", handlers.MarkNotificationAsRead)
+	authed.DELETE("/notifications/:notificationId", handlers.DeleteNotification)
+	authed.POST("/notifications/:notificationId/archive", handlers.ArchiveNotification)
+	authed.POST("/notifications/:notificationId/restore", handlers.RestoreNotification)
+
+	// Preferencias y silencios
+	authed.GET("/preferences", handlers.GetPreferences)
+	authed.PUT("/preferences", handlers.UpdatePreferences)
 
-	log.Println("HTTP server listening on :8001")
+	// Dispositivos para push
+	authed.POST("/devices", handlers.RegisterDevice)
+	authed.DELETE("/devices/:token", handlers.UnregisterDevice)
+
+	// Suscripciones de webhooks salientes y su registro de entregas
+	authed.POST("/webhooks", handlers.CreateWebhookSubscription)
+	authed.GET("/webhooks", handlers.GetWebhookSubscriptions)
+	authed.DELETE("/webhooks/:id", handlers.DeleteWebhookSubscription)
+	authed.GET("/webhooks/:id/deliveries", handlers.GetWebhookDeliveries)
+	authed.POST("/webhooks/:id/deliveries/:deliveryId/retry", handlers.RetryWebhookDelivery)
+
+	// API de soporte: cualquier usuario, restringida por rol y auditada en AdminAuditLogs
+	admin := authed.Group("/admin", middleware.RequireRole(handlers.AdminRoles()...))
+	admin.GET("/users/:userId/notifications", handlers.AdminGetNotifications)
+	admin.GET("/users/:userId/connection", handlers.AdminGetConnection)
+	admin.DELETE("/notifications/:notificationId", handlers.AdminDeleteNotification)
+	admin.POST("/notifications/:notificationId/resend", handlers.AdminResendNotification)
+	admin.GET("/notifications/:notificationId/timeline", handlers.AdminGetNotificationTimeline)
+	admin.GET("/audit-log", handlers.AdminGetAuditLog)
+
+	slog.Info("HTTP server listening", "addr", ":8001")
 	r.Run(":8001")
 }
+
+// rateLimitStore elige dónde se guardan los buckets: RATE_LIMIT_STORE=memory (por réplica, por defecto)
+// o postgres (compartidos entre réplicas, con purga de los buckets sin uso)
+func rateLimitStore() ratelimit.Store {
+	switch store := config.GetEnv("RATE_LIMIT_STORE", "memory"); store {
+	case "postgres":
+		pg := &ratelimit.PostgresStore{DB: config.DB}
+		go pg.PurgeIdle(10*time.Minute, time.Hour)
+		return pg
+	case "memory":
+		return ratelimit.NewMemoryStore()
+	default:
+		logger.Fatal("Unknown RATE_LIMIT_STORE", "store", store)
+		return nil
+	}
+}
+
+// pushProviders crea los proveedores push configurados por variables de entorno
+func pushProviders() map[string]push.PushProvider {
+	providers := map[string]push.PushProvider{}
+
+	if credentials := config.GetEnv("FCM_CREDENTIALS_FILE", ""); credentials != "" {
+		account, err := push.LoadServiceAccount(credentials)
+		if err != nil {
+			logger.Fatal("Failed to load FCM service account", "error", err)
+		}
+		fcm := push.NewFCMProvider(config.GetEnv("FCM_PROJECT_ID", ""), account, config.GetEnv("FCM_ENDPOINT", ""))
+		providers[models.PlatformAndroid] = fcm
+		providers[models.PlatformWeb] = fcm
+	}
+
+	if keyFile := config.GetEnv("APNS_KEY_FILE", ""); keyFile != "" {
+		key, err := push.LoadAPNsKey(keyFile)
+		if err != nil {
+			logger.Fatal("Failed to load APNs key", "error", err)
+		}
+		providers[models.PlatformIOS] = push.NewAPNsProvider(
+			config.GetEnv("APNS_KEY_ID", ""),
+			config.GetEnv("APNS_TEAM_ID", ""),
+			config.GetEnv("APNS_TOPIC", ""),
+			key,
+			config.GetEnv("APNS_ENDPOINT", ""),
+		)
+	}
+
+	return providers
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"notifications/config"
+	"notifications/logger"
+	"notifications/migrations"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// runMigrateCommand implementa "notifications migrate up|down [steps]|status"
+func runMigrateCommand(args []string) {
+	if len(args) == 0 {
+		logger.Fatal("usage: notifications migrate up|down [steps]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		if err := migrations.Up(config.DB); err != nil {
+			logger.Fatal("Migration failed", "error", err)
+		}
+		slog.Info("Migrations applied")
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			parsed, err := strconv.Atoi(args[1])
+			if err != nil || parsed < 1 {
+				logger.Fatal("Invalid steps: must be a positive integer", "steps", args[1])
+			}
+			steps = parsed
+		}
+		if err := migrations.Down(config.DB, steps); err != nil {
+			logger.Fatal("Migration revert failed", "error", err)
+		}
+		slog.Info("Migrations reverted", "steps", steps)
+
+	case "status":
+		statuses, err := migrations.GetStatus(config.DB)
+		if err != nil {
+			logger.Fatal("Could not read migration status", "error", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state, appliedAt := "pending", "-"
+			if s.Applied {
+				state, appliedAt = "applied", s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		w.Flush()
+
+	default:
+		logger.Fatal("Unknown migrate command (expected up, down or status)", "command", args[0])
+	}
+}
@@ -2,7 +2,10 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"notifications/logger"
+	"notifications/metrics"
+	"notifications/tracing"
 	"os"
 
 	"github.com/joho/godotenv"
@@ -15,7 +18,7 @@ var DB *gorm.DB
 func LoadEnv() {
 	err := godotenv.Load()
 	if err != nil {
-		log.Println("No .env file found, using environment variables directly.")
+		slog.Info("No .env file found, using environment variables directly")
 	}
 }
 
@@ -31,11 +34,21 @@ func ConnectDatabase() {
 		dbHost, dbUser, dbPassword, dbName, dbPort,
 	)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// El logger de GORM escribe en slog y nunca incluye los valores de las consultas
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Gorm()})
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		logger.Fatal("Failed to connect to database", "error", err)
+	}
+
+	// Latencia de cada sentencia para /metrics
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		logger.Fatal("Failed to register database metrics", "error", err)
+	}
+	// Un span por sentencia, hijo del span de la petición cuando la consulta usa WithContext
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		logger.Fatal("Failed to register database tracing", "error", err)
 	}
 
 	DB = db
-	log.Println("Connected to PostgreSQL!")
+	slog.Info("Connected to PostgreSQL", "host", dbHost, "database", dbName)
 }
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetEnv devuelve la variable de entorno o el valor por defecto si no está definida
+func GetEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// GetIntEnv lee un entero de una variable de entorno
+func GetIntEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("Invalid integer in environment, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// GetBoolEnv lee un booleano de una variable de entorno
+func GetBoolEnv(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("Invalid boolean in environment, using default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return parsed
+}
+
+// GetDurationEnv lee una duración (formato Go, ej. "15m") de una variable de entorno
+func GetDurationEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("Invalid duration in environment, using default", "key", key, "value", value, "default", fallback.String())
+		return fallback
+	}
+	return parsed
+}
@@ -0,0 +1,6 @@
+package dto
+
+// AdminActionRequest es el cuerpo opcional de las acciones de administración; el motivo queda en la auditoría
+type AdminActionRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
@@ -0,0 +1,6 @@
+package dto
+
+type RegisterDeviceRequest struct {
+	Token    string `json:"token" binding:"required,max=4096"`
+	Platform string `json:"platform" binding:"required,oneof=android ios web"`
+}
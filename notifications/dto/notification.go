@@ -1,5 +1,7 @@
 package dto
 
+import "github.com/google/uuid"
+
 type LikeWebhookRequest struct {
 	Event string          `json:"event" binding:"required"`
 	Data  LikeWebhookData `json:"data" binding:"required"`
@@ -12,4 +14,14 @@ type LikeWebhookData struct {
 	ResponsibleId string `json:"responsibleId" binding:"required"`
 	Timestamp     string `json:"timestamp" binding:"required"`
 	Content       string `json:"content" binding:"required"`
+	TargetId      string `json:"targetId"`
+}
+
+// WsMessageSeen es el tipo de mensaje con el que el cliente confirma que mostró notificaciones
+const WsMessageSeen = "seen"
+
+// WsClientMessage es un mensaje enviado por el cliente a través del WebSocket
+type WsClientMessage struct {
+	Type string      `json:"type"`
+	IDs  []uuid.UUID `json:"ids"`
 }
@@ -0,0 +1,27 @@
+package dto
+
+type PreferencesRequest struct {
+	// Email para el canal email; nil lo deja como está y "" lo elimina
+	Email       *string          `json:"email"`
+	Preferences []PreferenceData `json:"preferences" binding:"dive"`
+	Mutes       []MuteData       `json:"mutes" binding:"dive"`
+}
+
+type PreferenceData struct {
+	Type       string          `json:"type" binding:"required"`
+	Enabled    *bool           `json:"enabled" binding:"required"`
+	Channels   []string        `json:"channels" binding:"dive,oneof=websocket email push"`
+	QuietHours *QuietHoursData `json:"quietHours"`
+	Timezone   string          `json:"timezone"`
+}
+
+type QuietHoursData struct {
+	Start string `json:"start" binding:"required"`
+	End   string `json:"end" binding:"required"`
+}
+
+type MuteData struct {
+	ActorId   string `json:"actorId"`
+	Target    string `json:"target"`
+	ExpiresAt string `json:"expiresAt"`
+}
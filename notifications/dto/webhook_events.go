@@ -0,0 +1,43 @@
+package dto
+
+import "encoding/json"
+
+// WebhookEnvelope es el cuerpo común de POST /webhook/:source; Data se valida según Event
+type WebhookEnvelope struct {
+	Event string          `json:"event" binding:"required"`
+	Data  json.RawMessage `json:"data" binding:"required"`
+}
+
+type LikeCreatedData struct {
+	ActorId       string `json:"actorId" binding:"required,uuid"`
+	RecipientId   string `json:"recipientId" binding:"required,uuid"`
+	ResponsibleId string `json:"responsibleId" binding:"required,uuid"`
+	TargetId      string `json:"targetId" binding:"required,max=255"`
+	Content       string `json:"content" binding:"required,max=1000"`
+	Timestamp     string `json:"timestamp" binding:"required"`
+}
+
+type CommentCreatedData struct {
+	ActorId       string `json:"actorId" binding:"required,uuid"`
+	RecipientId   string `json:"recipientId" binding:"required,uuid"`
+	ResponsibleId string `json:"responsibleId" binding:"required,uuid"`
+	TargetId      string `json:"targetId" binding:"required,max=255"`
+	CommentId     string `json:"commentId" binding:"required,max=255"`
+	Content       string `json:"content" binding:"required,max=1000"`
+	Timestamp     string `json:"timestamp" binding:"required"`
+}
+
+type FollowCreatedData struct {
+	ActorId     string `json:"actorId" binding:"required,uuid"`
+	RecipientId string `json:"recipientId" binding:"required,uuid"`
+	Content     string `json:"content" binding:"required,max=1000"`
+	Timestamp   string `json:"timestamp" binding:"required"`
+}
+
+type MentionCreatedData struct {
+	ActorId     string `json:"actorId" binding:"required,uuid"`
+	RecipientId string `json:"recipientId" binding:"required,uuid"`
+	TargetId    string `json:"targetId" binding:"required,max=255"`
+	Content     string `json:"content" binding:"required,max=1000"`
+	Timestamp   string `json:"timestamp" binding:"required"`
+}
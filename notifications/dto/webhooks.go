@@ -0,0 +1,7 @@
+package dto
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" binding:"required,url,max=2048"`
+	EventTypes []string `json:"eventTypes" binding:"omitempty,dive,required,max=64"`
+	Secret     string   `json:"secret" binding:"omitempty,min=16,max=256"`
+}
@@ -0,0 +1,172 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"mime"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message es un email HTML listo para enviar
+type Message struct {
+	To      string
+	Subject string
+	HTML    string
+}
+
+// Sender envía emails; SMTPSender es la implementación real
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPSender envía por SMTP. Con Username vacío no se autentica,
+// lo que permite usar un servidor SMTP falso en proceso para pruebas.
+type SMTPSender struct {
+	Addr     string // host:port
+	Username string
+	Password string
+	From     string
+	// Timeout limita la conexión y la conversación completa con el servidor; por defecto defaultSMTPTimeout
+	Timeout time.Duration
+}
+
+// defaultSMTPTimeout evita que un servidor que acepta la conexión y no responde bloquee al worker
+const defaultSMTPTimeout = 30 * time.Second
+
+func (s *SMTPSender) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return defaultSMTPTimeout
+}
+
+// Send hace lo mismo que smtp.SendMail, pero con la conexión acotada por Timeout y por ctx: el plazo
+// se aplica al socket, así que ninguna lectura o escritura puede quedarse colgada.
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return err
+	}
+	if strings.ContainsAny(s.From+msg.To, "\r\n") {
+		return errors.New("smtp: A line must not contain CR or LF")
+	}
+
+	dialer := net.Dialer{Timeout: s.timeout()}
+	conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(s.timeout())
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+	// Cancelar ctx corta de inmediato cualquier operación en curso sobre el socket
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	err = s.deliver(conn, host, msg)
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (s *SMTPSender) deliver(conn net.Conn, host string, msg Message) error {
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildMIME(s.From, msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func buildMIME(from string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(msg.HTML)
+	return []byte(b.String())
+}
+
+// Retry define los reintentos con backoff exponencial
+type Retry struct {
+	Attempts int
+	Backoff  time.Duration // espera antes del segundo intento; se duplica en cada intento
+}
+
+// SendWithRetry reintenta los errores transitorios. Los rechazos permanentes del servidor
+// (respuestas SMTP 5xx, ej. destinatario inexistente) no se reintentan.
+func SendWithRetry(ctx context.Context, sender Sender, msg Message, retry Retry) (int, error) {
+	if retry.Attempts < 1 {
+		retry.Attempts = 1
+	}
+
+	var err error
+	wait := retry.Backoff
+	for attempt := 1; attempt <= retry.Attempts; attempt++ {
+		if err = sender.Send(ctx, msg); err == nil {
+			return attempt, nil
+		}
+		if isPermanent(err) || attempt == retry.Attempts {
+			return attempt, err
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		}
+		wait *= 2
+	}
+	return retry.Attempts, err
+}
+
+func isPermanent(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && protoErr.Code >= 500
+}
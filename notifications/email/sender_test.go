@@ -0,0 +1,225 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTP es un servidor SMTP mínimo en proceso. rcpt decide la respuesta a RCPT TO según el número
+// de intento (empezando en 1); los mensajes aceptados se guardan en messages.
+type fakeSMTP struct {
+	addr string
+	rcpt func(attempt int) string
+
+	mu       sync.Mutex
+	attempts int
+	messages []string
+}
+
+func startFakeSMTP(t *testing.T, rcpt func(attempt int) string) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &fakeSMTP{addr: ln.Addr().String(), rcpt: rcpt}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 fake")
+		case "MAIL":
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			s.mu.Lock()
+			s.attempts++
+			attempt := s.attempts
+			s.mu.Unlock()
+			tp.PrintfLine("%s", s.rcpt(attempt))
+		case "DATA":
+			tp.PrintfLine("354 end with <CRLF>.<CRLF>")
+			body, err := tp.ReadDotLines()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, strings.Join(body, "\n"))
+			s.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "RSET", "NOOP":
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func (s *fakeSMTP) stats() (int, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts, append([]string(nil), s.messages...)
+}
+
+func TestSMTPSenderWithRetry(t *testing.T) {
+	msg := Message{To: "ana@example.com", Subject: "Nueva notificación", HTML: "<p>hola</p>"}
+	retry := Retry{Attempts: 3, Backoff: time.Millisecond}
+
+	cases := []struct {
+		name         string
+		rcpt         func(attempt int) string
+		wantAttempts int
+		wantCode     int // 0 si debe enviarse
+	}{
+		{
+			name:         "success",
+			rcpt:         func(int) string { return "250 OK" },
+			wantAttempts: 1,
+		},
+		{
+			name: "transient 4xx is retried",
+			rcpt: func(attempt int) string {
+				if attempt < 3 {
+					return "451 4.3.0 try again later"
+				}
+				return "250 OK"
+			},
+			wantAttempts: 3,
+		},
+		{
+			name:         "permanent 5xx is not retried",
+			rcpt:         func(int) string { return "550 5.1.1 no such user" },
+			wantAttempts: 1,
+			wantCode:     550,
+		},
+		{
+			name:         "transient 4xx gives up after the last attempt",
+			rcpt:         func(int) string { return "421 4.7.0 service not available" },
+			wantAttempts: 3,
+			wantCode:     421,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := startFakeSMTP(t, tc.rcpt)
+			sender := &SMTPSender{Addr: server.addr, From: "noreply@example.com"}
+
+			attempts, err := SendWithRetry(context.Background(), sender, msg, retry)
+			if attempts != tc.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tc.wantAttempts)
+			}
+			serverAttempts, messages := server.stats()
+			if serverAttempts != tc.wantAttempts {
+				t.Errorf("server saw %d attempts, want %d", serverAttempts, tc.wantAttempts)
+			}
+
+			if tc.wantCode != 0 {
+				var protoErr *textproto.Error
+				if !errors.As(err, &protoErr) || protoErr.Code != tc.wantCode {
+					t.Fatalf("err = %v, want SMTP %d", err, tc.wantCode)
+				}
+				if len(messages) != 0 {
+					t.Errorf("server accepted %d messages, want 0", len(messages))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("SendWithRetry: %v", err)
+			}
+			if len(messages) != 1 {
+				t.Fatalf("server accepted %d messages, want 1", len(messages))
+			}
+			headers, body, _ := strings.Cut(messages[0], "\n\n")
+			if !strings.Contains(headers, "To: ana@example.com") ||
+				!strings.Contains(headers, "Subject: =?utf-8?q?Nueva_notificaci=C3=B3n?=") {
+				t.Errorf("unexpected headers:\n%s", headers)
+			}
+			if body != msg.HTML {
+				t.Errorf("body = %q, want %q", body, msg.HTML)
+			}
+		})
+	}
+}
+
+// Sin servidor (conexión rechazada) el error es transitorio y se reintenta
+func TestSendWithRetryConnectionRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	sender := &SMTPSender{Addr: addr, From: "noreply@example.com"}
+	attempts, err := SendWithRetry(context.Background(), sender, Message{To: "ana@example.com"}, Retry{Attempts: 2, Backoff: time.Millisecond})
+	if err == nil || attempts != 2 {
+		t.Errorf("attempts = %d, err = %v; want 2 attempts and an error", attempts, err)
+	}
+}
+
+// Un servidor que acepta la conexión y no responde no bloquea el envío más allá de Timeout
+func TestSMTPSenderTimesOutOnSilentServer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	sender := &SMTPSender{Addr: ln.Addr().String(), From: "noreply@example.com", Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	if err := sender.Send(context.Background(), Message{To: "ana@example.com"}); err == nil {
+		t.Fatal("Send succeeded against a silent server")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send took %v, want it bounded by the 100ms timeout", elapsed)
+	}
+
+	// Cancelar el contexto corta la conversación aunque el timeout sea largo
+	sender.Timeout = time.Minute
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := sender.Send(ctx, Message{To: "ana@example.com"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,82 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"html"
+	"html/template"
+	"io/fs"
+	"notifications/models"
+	"strings"
+	"time"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+// defaultTemplate se usa para los tipos sin plantilla propia
+const defaultTemplate = "default"
+
+// TemplateData son los datos disponibles en las plantillas
+type TemplateData struct {
+	Type       string
+	Content    string
+	Timestamp  time.Time
+	Count      int // notificaciones agrupadas (1 si no está agrupada)
+	ActorCount int
+}
+
+// Templates contiene una plantilla por Type de notificación (templates/<type>.html),
+// cada una con los bloques "subject" y "body"
+type Templates struct {
+	byType map[string]*template.Template
+}
+
+// LoadTemplates carga las plantillas embebidas
+func LoadTemplates() (*Templates, error) {
+	entries, err := fs.Glob(templateFiles, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{byType: map[string]*template.Template{}}
+	for _, path := range entries {
+		name := strings.TrimSuffix(strings.TrimPrefix(path, "templates/"), ".html")
+		tmpl, err := template.ParseFS(templateFiles, path)
+		if err != nil {
+			return nil, err
+		}
+		t.byType[name] = tmpl
+	}
+	return t, nil
+}
+
+// Render devuelve asunto y cuerpo HTML para la notificación
+func (t *Templates) Render(noti models.Notification, group *models.NotificationGroup) (string, string, error) {
+	tmpl, ok := t.byType[noti.Type]
+	if !ok {
+		tmpl = t.byType[defaultTemplate]
+	}
+
+	data := TemplateData{
+		Type:       noti.Type,
+		Content:    noti.Content,
+		Timestamp:  noti.Timestamp,
+		Count:      1,
+		ActorCount: 1,
+	}
+	if group != nil {
+		data.Count = group.Count
+		data.ActorCount = group.ActorCount
+	}
+
+	var subject, body bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return "", "", err
+	}
+	if err := tmpl.ExecuteTemplate(&body, "body", data); err != nil {
+		return "", "", err
+	}
+	// El asunto no es HTML: se deshace el escapado de html/template
+	return strings.TrimSpace(html.UnescapeString(subject.String())), body.String(), nil
+}
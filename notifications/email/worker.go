@@ -0,0 +1,238 @@
+package email
+
+import (
+	"context"
+	"log/slog"
+	"notifications/clock"
+	"notifications/health"
+	"notifications/lifecycle"
+	"notifications/models"
+	"notifications/preferences"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Worker envía por email las notificaciones no leídas de usuarios que llevan
+// más de OfflineDelay desconectados y que tienen el canal email activado
+type Worker struct {
+	DB        *gorm.DB
+	Sender    Sender
+	Templates *Templates
+	Clock     clock.Clock
+
+	Interval     time.Duration
+	OfflineDelay time.Duration
+	// MaxAge evita enviar notificaciones antiguas al activar el canal
+	MaxAge time.Duration
+	// RateLimitPerHour es el máximo de emails por usuario en la última hora
+	RateLimitPerHour int
+	Retry            Retry
+	BatchSize        int
+	// Lease es cuánto puede seguir una entrega en sending antes de que otra vuelta (de esta u otra
+	// réplica) la dé por abandonada y la reintente; el envío se corta al agotarlo. Por defecto defaultLease
+	Lease time.Duration
+	// Heartbeat late en cada vuelta para /readyz; opcional
+	Heartbeat *health.Heartbeat
+}
+
+// Run revisa candidatos en cada tick hasta que ctx se cancele
+func (w *Worker) Run(ctx context.Context) {
+	ticker := w.Clock.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := w.RunOnce(ctx)
+		if err != nil {
+			slog.Error("Email worker error", "error", err)
+		}
+		w.Heartbeat.Beat(err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// defaultLease cubre los intentos de SendWithRetry con el timeout SMTP por defecto
+const defaultLease = 10 * time.Minute
+
+func (w *Worker) lease() time.Duration {
+	if w.Lease > 0 {
+		return w.Lease
+	}
+	return defaultLease
+}
+
+// candidate es una notificación pendiente de email junto con el destinatario
+type candidate struct {
+	models.Notification
+	Email string `gorm:"column:email"`
+}
+
+// candidatesQuery selecciona las notificaciones que esperan email en el instante now
+func (w *Worker) candidatesQuery(ctx context.Context, now time.Time) *gorm.DB {
+	offlineSince := now.Add(-w.OfflineDelay)
+	return w.DB.WithContext(ctx).
+		Unscoped(). // "deletedAt" se filtra explícitamente con el alias n
+		Table(`"Notifications" AS n`).
+		Joins(`JOIN "UserContacts" uc ON uc."userId" = n."responsibleId" AND uc.email <> ''`).
+		Joins(`LEFT JOIN "UserPresence" up ON up."userId" = n."responsibleId"`).
+		Where(`n.read = ? AND n.archived = ? AND n."deletedAt" IS NULL`, false, false).
+		Where(`n.timestamp <= ? AND n.timestamp >= ?`, offlineSince, now.Add(-w.MaxAge)).
+		Where(`(up."userId" IS NULL OR (up.connected = ? AND up."lastSeenAt" <= ?))`, false, offlineSince).
+		// Solo la más reciente de cada grupo, sin las retenidas por horario de silencio
+		Where(`(n."groupId" IS NULL OR EXISTS (SELECT 1 FROM "NotificationGroups" g WHERE g.id = n."groupId" AND g."latestNotificationId" = n.id))`).
+		Where(`n.id NOT IN (SELECT "notificationId" FROM "DigestItems" WHERE "deliveredAt" IS NULL)`).
+		// Ya enviadas o en curso, salvo las que llevan en sending más que el lease
+		Where(`NOT EXISTS (SELECT 1 FROM "EmailDeliveries" ed WHERE ed."notificationId" = n.id AND (ed.status <> ? OR ed."claimedAt" > ?))`,
+			models.EmailStatusSending, now.Add(-w.lease()))
+}
+
+// Pending cuenta las notificaciones que esperan email; alimenta la métrica queue_depth
+func (w *Worker) Pending(ctx context.Context) (int64, error) {
+	var n int64
+	err := w.candidatesQuery(ctx, w.Clock.Now()).Count(&n).Error
+	return n, err
+}
+
+// RunOnce procesa un lote de candidatos y devuelve cuántos emails se enviaron
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	now := w.Clock.Now()
+
+	var candidates []candidate
+	err := w.candidatesQuery(ctx, now).
+		Select(`n.*, uc.email`).
+		Order("n.timestamp").
+		Limit(w.BatchSize).
+		Find(&candidates).Error
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, c := range candidates {
+		ok, err := w.process(ctx, c, now)
+		if err != nil {
+			slog.Warn("Email delivery failed", "notification_id", c.ID, "error", err)
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+func (w *Worker) process(ctx context.Context, c candidate, now time.Time) (bool, error) {
+	pref, err := preferences.Resolve(w.DB, c.ResponsibleID, c.Type)
+	if err != nil {
+		return false, err
+	}
+	// Sin email para este tipo se registra igualmente la fila: si no, la notificación seguiría siendo
+	// candidata en cada vuelta y ocuparía el lote hasta cumplir MaxAge, dejando fuera a las que sí se envían
+	if !pref.Enabled || !pref.HasChannel(models.ChannelEmail) {
+		skipped := models.EmailDelivery{
+			NotificationID: c.ID,
+			UserID:         c.ResponsibleID,
+			Status:         models.EmailStatusSkipped,
+			LastError:      "email channel disabled",
+			CreatedAt:      now,
+		}
+		return false, w.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&skipped).Error
+	}
+
+	// Reclamar la notificación: si otra réplica ya insertó la fila, no se envía de nuevo, salvo que
+	// lleve en sending más que el lease (la réplica murió a mitad del envío). claimedAt se trunca a
+	// microsegundos, la precisión de Postgres, porque hace de token en finish.
+	claimedAt := now.Truncate(time.Microsecond)
+	delivery := models.EmailDelivery{
+		NotificationID: c.ID,
+		UserID:         c.ResponsibleID,
+		Status:         models.EmailStatusSending,
+		CreatedAt:      now,
+		ClaimedAt:      &claimedAt,
+	}
+	result := w.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "notificationId"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"claimedAt": claimedAt}),
+		Where: clause.Where{Exprs: []clause.Expression{gorm.Expr(
+			`"EmailDeliveries".status = ? AND ("EmailDeliveries"."claimedAt" IS NULL OR "EmailDeliveries"."claimedAt" <= ?)`,
+			models.EmailStatusSending, now.Add(-w.lease()))}},
+	}).Create(&delivery)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	finish := func(status string, attempts int, lastError string, sentAt *time.Time) error {
+		return w.finish(c.ID, claimedAt, status, attempts, lastError, sentAt)
+	}
+
+	limited, err := w.rateLimited(c.ResponsibleID, now)
+	if err != nil {
+		return false, err
+	}
+	if limited {
+		slog.Info("Email rate limit reached, skipping notification", "user_id", c.ResponsibleID, "notification_id", c.ID)
+		lifecycle.Delivery(w.DB, c.ID, c.ResponsibleID, models.ChannelEmail, models.DeliveryResultSkipped, "rate limited")
+		return false, finish(models.EmailStatusRateLimited, 0, "", nil)
+	}
+
+	var group *models.NotificationGroup
+	if c.GroupID != nil {
+		var g models.NotificationGroup
+		if err := w.DB.First(&g, "id = ?", *c.GroupID).Error; err == nil {
+			group = &g
+		}
+	}
+
+	subject, body, err := w.Templates.Render(c.Notification, group)
+	if err != nil {
+		lifecycle.Delivery(w.DB, c.ID, c.ResponsibleID, models.ChannelEmail, models.DeliveryResultFailed, err.Error())
+		return false, finish(models.EmailStatusFailed, 0, err.Error(), nil)
+	}
+
+	// El envío no puede durar más que el lease: pasado ese plazo otra vuelta podría reclamarlo
+	sendCtx, cancel := context.WithTimeout(ctx, w.lease())
+	defer cancel()
+	attempts, err := SendWithRetry(sendCtx, w.Sender, Message{To: c.Email, Subject: subject, HTML: body}, w.Retry)
+	lifecycle.Delivery(w.DB, c.ID, c.ResponsibleID, models.ChannelEmail, lifecycle.Result(err), lifecycle.ErrorDetail(err))
+	if err != nil {
+		return false, finish(models.EmailStatusFailed, attempts, err.Error(), nil)
+	}
+
+	sentAt := w.Clock.Now()
+	return true, finish(models.EmailStatusSent, attempts, "", &sentAt)
+}
+
+// rateLimited indica si el usuario ya recibió RateLimitPerHour emails en la última hora
+func (w *Worker) rateLimited(userID uuid.UUID, now time.Time) (bool, error) {
+	if w.RateLimitPerHour <= 0 {
+		return false, nil
+	}
+	var count int64
+	err := w.DB.Model(&models.EmailDelivery{}).
+		Where(`"userId" = ? AND status = ? AND "sentAt" > ?`, userID, models.EmailStatusSent, now.Add(-time.Hour)).
+		Count(&count).Error
+	return count >= int64(w.RateLimitPerHour), err
+}
+
+// finish guarda el resultado si la entrega sigue reclamada con claimedAt; si el lease venció y otra
+// vuelta la reclamó, prevalece el resultado de esa
+func (w *Worker) finish(notificationID uuid.UUID, claimedAt time.Time, status string, attempts int, lastError string, sentAt *time.Time) error {
+	result := w.DB.Model(&models.EmailDelivery{}).
+		Where(`"notificationId" = ? AND status = ? AND "claimedAt" = ?`, notificationID, models.EmailStatusSending, claimedAt).
+		Updates(map[string]interface{}{
+			"status":    status,
+			"attempts":  attempts,
+			"lastError": lastError,
+			"sentAt":    sentAt,
+		})
+	if result.Error == nil && result.RowsAffected == 0 {
+		slog.Warn("Email delivery lease expired before recording the result", "notification_id", notificationID)
+	}
+	return result.Error
+}
@@ -0,0 +1,170 @@
+package email
+
+import (
+	"context"
+	"notifications/clock"
+	"notifications/internal/testdb"
+	"notifications/models"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// recordingSender guarda los mensajes en memoria en lugar de enviarlos
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+func (s *recordingSender) Send(_ context.Context, msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+// offlineUser crea un usuario con email y una notificación no leída en at
+func offlineUser(t *testing.T, db *gorm.DB, email string, at time.Time) models.Notification {
+	t.Helper()
+	userID := uuid.New()
+	if err := db.Create(&models.UserContact{UserID: userID, Email: email, UpdatedAt: at}).Error; err != nil {
+		t.Fatal(err)
+	}
+	noti := models.Notification{
+		ActorID:       uuid.New(),
+		RecipientID:   uuid.New(),
+		ResponsibleID: userID,
+		Type:          "like",
+		Content:       "offline",
+		Target:        "post",
+		Timestamp:     at,
+	}
+	if err := db.Create(&noti).Error; err != nil {
+		t.Fatal(err)
+	}
+	return noti
+}
+
+func TestWorkerSkipsDisabledWithoutStarvingTheBatch(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	// La más antigua es de un usuario sin el canal email (preferencia por defecto)
+	disabled := offlineUser(t, db, "off@example.com", now.Add(-2*time.Hour))
+	enabled := offlineUser(t, db, "on@example.com", now.Add(-time.Hour))
+	if err := db.Create(&models.NotificationPreference{
+		UserID:   enabled.ResponsibleID,
+		Type:     models.DefaultPreferenceType,
+		Enabled:  true,
+		Channels: models.StringList{models.ChannelWebSocket, models.ChannelEmail},
+		Timezone: "UTC",
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := LoadTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &recordingSender{}
+	w := &Worker{
+		DB:           db,
+		Sender:       sender,
+		Templates:    templates,
+		Clock:        clock.NewFake(now),
+		OfflineDelay: 15 * time.Minute,
+		MaxAge:       24 * time.Hour,
+		Retry:        Retry{Attempts: 1},
+		BatchSize:    1, // el lote solo cabe una: la omitida no debe volver a ocuparlo
+	}
+
+	for run := 0; run < 3; run++ {
+		if _, err := w.RunOnce(ctx); err != nil {
+			t.Fatalf("RunOnce: %v", err)
+		}
+	}
+
+	if len(sender.sent) != 1 || sender.sent[0].To != "on@example.com" {
+		t.Fatalf("sent = %+v, want one email to on@example.com", sender.sent)
+	}
+	var skipped models.EmailDelivery
+	if err := db.First(&skipped, `"notificationId" = ?`, disabled.ID).Error; err != nil {
+		t.Fatalf("disabled notification was not marked: %v", err)
+	}
+	if skipped.Status != models.EmailStatusSkipped {
+		t.Errorf("status = %q, want %q", skipped.Status, models.EmailStatusSkipped)
+	}
+}
+
+func TestWorkerRetriesStaleSendingDeliveries(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	noti := offlineUser(t, db, "on@example.com", now.Add(-time.Hour))
+	if err := db.Create(&models.NotificationPreference{
+		UserID:   noti.ResponsibleID,
+		Type:     models.DefaultPreferenceType,
+		Enabled:  true,
+		Channels: models.StringList{models.ChannelEmail},
+		Timezone: "UTC",
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Una réplica reclamó el envío y murió sin registrar el resultado
+	claimedAt := now.Add(-time.Minute)
+	if err := db.Create(&models.EmailDelivery{
+		NotificationID: noti.ID,
+		UserID:         noti.ResponsibleID,
+		Status:         models.EmailStatusSending,
+		CreatedAt:      claimedAt,
+		ClaimedAt:      &claimedAt,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := LoadTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := clock.NewFake(now)
+	sender := &recordingSender{}
+	w := &Worker{
+		DB:           db,
+		Sender:       sender,
+		Templates:    templates,
+		Clock:        fake,
+		OfflineDelay: 15 * time.Minute,
+		MaxAge:       24 * time.Hour,
+		Retry:        Retry{Attempts: 1},
+		BatchSize:    10,
+		Lease:        5 * time.Minute,
+	}
+
+	// Mientras el lease está vigente no se envía de nuevo
+	if sent, err := w.RunOnce(ctx); err != nil || sent != 0 {
+		t.Fatalf("RunOnce under a live lease = %d, %v; want 0", sent, err)
+	}
+
+	// Vencido el lease se reclama y se envía una sola vez
+	fake.Advance(5 * time.Minute)
+	for run := 0; run < 2; run++ {
+		if _, err := w.RunOnce(ctx); err != nil {
+			t.Fatalf("RunOnce: %v", err)
+		}
+	}
+	if len(sender.sent) != 1 {
+		t.Fatalf("sent %d emails after the lease expired, want 1", len(sender.sent))
+	}
+	var delivery models.EmailDelivery
+	if err := db.First(&delivery, `"notificationId" = ?`, noti.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Status != models.EmailStatusSent {
+		t.Errorf("status = %q, want %q", delivery.Status, models.EmailStatusSent)
+	}
+}
@@ -0,0 +1,223 @@
+package grpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"notifications/config"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata en la que los servicios envían sus credenciales
+const (
+	authorizationMetadata = "authorization" // "Bearer <JWT de servicio>"
+	apiKeyMetadata        = "x-api-key"
+)
+
+// AnyService en la ACL permite el método a cualquier servicio autenticado
+const AnyService = "*"
+
+type identityKey struct{}
+
+// ServiceIdentity devuelve el servicio que hizo la llamada, tal como lo autenticó el interceptor
+func ServiceIdentity(ctx context.Context) (string, bool) {
+	identity, ok := ctx.Value(identityKey{}).(string)
+	return identity, ok
+}
+
+// Authenticator autentica al servicio que llama (JWT de servicio, API key o certificado de
+// cliente) y autoriza cada método según la ACL por identidad
+type Authenticator struct {
+	// JWTSecret firma los JWT de servicio (HS256); la identidad es el claim sub
+	JWTSecret []byte
+	// JWTAudience, si no está vacío, debe aparecer en el claim aud
+	JWTAudience string
+	// APIKeys asocia cada API key con la identidad del servicio
+	APIKeys map[string]string
+	// MethodACL asocia el nombre corto del método (FollowCreated) con las identidades permitidas
+	MethodACL map[string][]string
+	// TrustClientCerts usa el CN del certificado de cliente verificado por mTLS como identidad
+	// cuando la llamada no trae credenciales en metadata
+	TrustClientCerts bool
+	// Public son métodos completos que no requieren autenticación (p.ej. health checks)
+	Public map[string]bool
+}
+
+// UnaryInterceptor aplica autenticación y autorización a las llamadas unarias
+func (a *Authenticator) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor aplica autenticación y autorización a las llamadas de streaming
+func (a *Authenticator) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authorize(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &identifiedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// identifiedStream expone el contexto con la identidad a los handlers de streaming
+type identifiedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identifiedStream) Context() context.Context { return s.ctx }
+
+func (a *Authenticator) authorize(ctx context.Context, fullMethod string) (context.Context, error) {
+	if a.Public[fullMethod] {
+		return ctx, nil
+	}
+
+	identity, err := a.authenticate(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Rejected gRPC call", "method", fullMethod, "error", err)
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	if !allowed(a.MethodACL[method], identity) {
+		slog.WarnContext(ctx, "Service is not allowed to call method", "service", identity, "method", fullMethod)
+		return nil, status.Errorf(codes.PermissionDenied, "service %q is not allowed to call %s", identity, method)
+	}
+
+	return context.WithValue(ctx, identityKey{}, identity), nil
+}
+
+func (a *Authenticator) authenticate(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if values := md.Get(authorizationMetadata); len(values) > 0 {
+		token, found := strings.CutPrefix(values[0], "Bearer ")
+		if !found {
+			return "", fmt.Errorf("invalid authorization metadata format")
+		}
+		return a.serviceFromJWT(token)
+	}
+
+	if values := md.Get(apiKeyMetadata); len(values) > 0 {
+		for key, identity := range a.APIKeys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(values[0])) == 1 {
+				return identity, nil
+			}
+		}
+		return "", fmt.Errorf("invalid API key")
+	}
+
+	if a.TrustClientCerts {
+		if p, ok := peer.FromContext(ctx); ok {
+			if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+				if cn := info.State.VerifiedChains[0][0].Subject.CommonName; cn != "" {
+					return cn, nil
+				}
+			}
+		}
+	}
+
+	return "", fmt.Errorf("missing service credentials")
+}
+
+func (a *Authenticator) serviceFromJWT(tokenString string) (string, error) {
+	if len(a.JWTSecret) == 0 {
+		return "", fmt.Errorf("service JWT not accepted")
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "HS384", "HS512"}),
+		jwt.WithExpirationRequired(),
+	}
+	if a.JWTAudience != "" {
+		options = append(options, jwt.WithAudience(a.JWTAudience))
+	}
+
+	var claims jwt.RegisteredClaims
+	if _, err := jwt.ParseWithClaims(tokenString, &claims, func(*jwt.Token) (interface{}, error) {
+		return a.JWTSecret, nil
+	}, options...); err != nil {
+		return "", fmt.Errorf("invalid service token: %w", err)
+	}
+	if claims.Subject == "" {
+		return "", fmt.Errorf("service token has no sub claim")
+	}
+	return claims.Subject, nil
+}
+
+func allowed(identities []string, identity string) bool {
+	for _, allowed := range identities {
+		if allowed == AnyService || allowed == identity {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticatorFromEnv construye el Authenticator a partir de:
+//
+//	GRPC_SERVICE_JWT_SECRET / GRPC_SERVICE_JWT_AUDIENCE
+//	GRPC_API_KEYS=users-service=key1,posts-service=key2
+//	GRPC_METHOD_ACL=FollowCreated=users-service|posts-service;OtroMetodo=*
+//	GRPC_MTLS_IDENTITY=true
+func authenticatorFromEnv() *Authenticator {
+	a := &Authenticator{
+		JWTSecret:        []byte(config.GetEnv("GRPC_SERVICE_JWT_SECRET", "")),
+		JWTAudience:      config.GetEnv("GRPC_SERVICE_JWT_AUDIENCE", ""),
+		APIKeys:          map[string]string{},
+		MethodACL:        map[string][]string{},
+		TrustClientCerts: config.GetBoolEnv("GRPC_MTLS_IDENTITY", false),
+		Public:           map[string]bool{},
+	}
+
+	for _, entry := range splitList(config.GetEnv("GRPC_API_KEYS", ""), ",") {
+		identity, key, ok := strings.Cut(entry, "=")
+		if !ok || identity == "" || key == "" {
+			slog.Warn("Ignoring malformed GRPC_API_KEYS entry", "service", identity)
+			continue
+		}
+		a.APIKeys[key] = identity
+	}
+
+	for _, entry := range splitList(config.GetEnv("GRPC_METHOD_ACL", ""), ";") {
+		method, identities, ok := strings.Cut(entry, "=")
+		if !ok || method == "" {
+			slog.Warn("Ignoring malformed GRPC_METHOD_ACL entry", "entry", entry)
+			continue
+		}
+		a.MethodACL[method] = splitList(identities, "|")
+	}
+
+	if len(a.MethodACL) == 0 {
+		slog.Warn("GRPC_METHOD_ACL is empty; every gRPC method will be denied (e.g. GRPC_METHOD_ACL=FollowCreated=users-service)")
+	}
+	if len(a.JWTSecret) == 0 && len(a.APIKeys) == 0 && !a.TrustClientCerts {
+		slog.Warn("No gRPC service credentials configured; every call will be rejected")
+	}
+	return a
+}
+
+func splitList(value, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,92 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"notifications/config"
+	"notifications/handlers"
+	"notifications/health"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	pb "notifications/proto/notificationpb"
+)
+
+// listenerState es el estado del listener gRPC que muestra /readyz
+var listenerState = struct {
+	sync.Mutex
+	addr    string
+	tls     bool
+	serving bool
+	err     error
+}{err: errors.New("gRPC server not started")}
+
+func setListenerState(addr string, tls, serving bool, err error) {
+	listenerState.Lock()
+	defer listenerState.Unlock()
+	listenerState.addr = addr
+	listenerState.tls = tls
+	listenerState.serving = serving
+	listenerState.err = err
+}
+
+// ListenerCheck falla si el servidor gRPC no pudo abrir su puerto o dejó de servir
+func ListenerCheck(ctx context.Context) (map[string]interface{}, error) {
+	listenerState.Lock()
+	defer listenerState.Unlock()
+
+	detail := map[string]interface{}{
+		"addr":    listenerState.addr,
+		"tls":     listenerState.tls,
+		"serving": listenerState.serving,
+	}
+	return detail, listenerState.err
+}
+
+// healthMethods son los métodos de grpc.health.v1, públicos para que los balanceadores
+// y las sondas de Kubernetes no necesiten credenciales
+var healthMethods = []string{
+	healthpb.Health_Check_FullMethodName,
+	healthpb.Health_List_FullMethodName,
+	healthpb.Health_Watch_FullMethodName,
+}
+
+// registerHealth registra grpc.health.v1 en el servidor
+func registerHealth(server *grpc.Server) *grpchealth.Server {
+	healthServer := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	return healthServer
+}
+
+// watchReadiness refleja en grpc.health.v1 el resultado de las comprobaciones de /readyz, tanto
+// para el servidor ("") como para NotificationService
+func watchReadiness(ctx context.Context, healthServer *grpchealth.Server) {
+	interval := config.GetDurationEnv("GRPC_HEALTH_INTERVAL", 10*time.Second)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_UNKNOWN
+	for {
+		status := healthpb.HealthCheckResponse_SERVING
+		if healthy, _ := health.Run(ctx, handlers.ReadinessChecks, handlers.HealthCheckTimeout()); !healthy {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		if status != last {
+			slog.Info("gRPC health status changed", "status", status.String())
+			last = status
+		}
+		healthServer.SetServingStatus("", status)
+		healthServer.SetServingStatus(pb.NotificationService_ServiceDesc.ServiceName, status)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package grpc
+
+import (
+	"context"
+	"log/slog"
+	"notifications/logger"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// requestIDMetadata propaga el request ID entre servicios, igual que X-Request-ID en HTTP
+const requestIDMetadata = "x-request-id"
+
+// withRequestID toma el request ID del metadata (o genera uno), lo devuelve en el header de
+// respuesta y lo deja en el contexto para los logs
+func withRequestID(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := ""
+	if values := md.Get(requestIDMetadata); len(values) > 0 && len(values[0]) <= 128 {
+		id = values[0]
+	}
+	if id == "" {
+		id = uuid.NewString()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, id))
+	return logger.WithRequestID(ctx, id)
+}
+
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+	}
+	slog.LogAttrs(ctx, level, "gRPC call",
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Duration("latency", time.Since(start)),
+	)
+}
+
+// LoggingUnaryInterceptor asigna el request ID y registra cada llamada unaria con su código
+func LoggingUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx = withRequestID(ctx)
+		resp, err := handler(ctx, req)
+		logCall(ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// LoggingStreamInterceptor hace lo mismo para las llamadas de streaming
+func LoggingStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := withRequestID(ss.Context())
+		err := handler(srv, &identifiedStream{ServerStream: ss, ctx: ctx})
+		logCall(ctx, info.FullMethod, start, err)
+		return err
+	}
+}
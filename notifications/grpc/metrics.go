@@ -0,0 +1,35 @@
+package grpc
+
+import (
+	"context"
+	"notifications/metrics"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+func observeCall(method string, start time.Time, err error) {
+	metrics.GRPCRequestDuration.WithLabelValues(method, status.Code(err).String()).Observe(time.Since(start).Seconds())
+}
+
+// MetricsUnaryInterceptor mide cada llamada unaria por método y código, incluidas las rechazadas
+// por autenticación o límites
+func MetricsUnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		observeCall(info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// MetricsStreamInterceptor hace lo mismo para las llamadas de streaming
+func MetricsStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		observeCall(info.FullMethod, start, err)
+		return err
+	}
+}
@@ -0,0 +1,78 @@
+package grpc
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"notifications/handlers"
+	"notifications/middleware"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// RateLimiter aplica el límite de ingesta por servicio autenticado (o por IP del cliente si la
+// llamada no tiene identidad). Va después del Authenticator para conocer la identidad.
+type RateLimiter struct {
+	// Public son métodos que no se limitan (p.ej. health checks)
+	Public map[string]bool
+}
+
+// UnaryInterceptor devuelve RESOURCE_EXHAUSTED con el trailer retry-after al agotar el bucket
+func (l *RateLimiter) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := l.take(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor consume un token al abrir el stream
+func (l *RateLimiter) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := l.take(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func (l *RateLimiter) take(ctx context.Context, fullMethod string) error {
+	limit := handlers.IngestLimit()
+	if l.Public[fullMethod] || handlers.RateLimits == nil || !limit.Enabled() {
+		return nil
+	}
+
+	key := producerKey(ctx)
+	decision, err := handlers.RateLimits.Take(ctx, "ingest:"+key, limit)
+	if err != nil {
+		slog.WarnContext(ctx, "Rate limit store unavailable, allowing call", "error", err)
+		return nil
+	}
+	if !decision.Allowed {
+		slog.WarnContext(ctx, "Ingestion rate limit exceeded", "producer", key, "method", fullMethod)
+		grpc.SetTrailer(ctx, metadata.Pairs("retry-after", strconv.Itoa(middleware.RetryAfterSeconds(decision))))
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+	return nil
+}
+
+// producerKey usa la identidad del servicio o, sin ella, la IP del peer
+func producerKey(ctx context.Context) string {
+	if identity, ok := ServiceIdentity(ctx); ok {
+		return "service:" + identity
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return "ip:" + host
+	}
+	return "ip:unknown"
+}
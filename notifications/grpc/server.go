@@ -2,17 +2,25 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"notifications/config"
 	"notifications/handlers"
+	"notifications/logger"
 	"notifications/models"
+	"notifications/preferences"
+	"notifications/utils"
 	"time"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
-	"gorm.io/gorm/clause"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	pb "notifications/proto/notificationpb"
 )
@@ -23,19 +31,32 @@ type NotificationGRPCServer struct {
 
 // Método que maneja la llamada FollowCreated
 func (s *NotificationGRPCServer) FollowCreated(ctx context.Context, req *pb.FollowCreatedRequest) (*pb.NotificationResponse, error) {
+	if caller, ok := ServiceIdentity(ctx); ok {
+		slog.DebugContext(ctx, "FollowCreated called", "service", caller)
+	}
+
 	actorID, err := uuid.Parse(req.GetActorId())
 	if err != nil {
-		return nil, fmt.Errorf("invalid actorId: %w", err)
+		return nil, status.Error(codes.InvalidArgument, "invalid actorId: "+err.Error())
 	}
 
 	recipientID, err := uuid.Parse(req.GetRecipientId())
 	if err != nil {
-		return nil, fmt.Errorf("invalid recipientId: %w", err)
+		return nil, status.Error(codes.InvalidArgument, "invalid recipientId: "+err.Error())
 	}
 
 	responsibleID, err := uuid.Parse(req.GetResponsibleId())
 	if err != nil {
-		return nil, fmt.Errorf("invalid responsibleId: %w", err)
+		return nil, status.Error(codes.InvalidArgument, "invalid responsibleId: "+err.Error())
+	}
+
+	// Se respeta el timestamp del productor; si no lo envía se usa la hora de recepción
+	timestamp := time.Now().UTC()
+	if req.GetTimestamp() != "" {
+		timestamp, err = utils.ParseTimestamp(req.GetTimestamp())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
 	}
 
 	noti := models.Notification{
@@ -44,40 +65,27 @@ func (s *NotificationGRPCServer) FollowCreated(ctx context.Context, req *pb.Foll
 		ResponsibleID: responsibleID,
 		Type:          req.GetType(),
 		Content:       req.GetContent(),
-		Timestamp:     time.Now(),
+		Timestamp:     timestamp,
 	}
 
-	if err := config.DB.
-		Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "actorId"}, {Name: "recipientId"}, {Name: "type"}, {Name: "content"}},
-			DoUpdates: clause.AssignmentColumns([]string{"timestamp"}),
-		}).
-		Create(&noti).Error; err != nil {
+	// Un mismo follow actualiza la fila activa existente gracias al índice único sobre dedupeKey
+	noti.DedupeKey = handlers.DedupeKey(noti.ActorID.String(), noti.RecipientID.String(), noti.Type, noti.Content)
+
+	replayed, err := handlers.CreateNotificationOnce(ctx, "grpc:FollowCreated", req.GetIdempotencyKey(), &noti, handlers.DedupeConflict())
+	if errors.Is(err, handlers.ErrIdempotencyKeyTooLong) {
+		return nil, status.Error(codes.InvalidArgument, "idempotencyKey too long")
+	}
+	if errors.Is(err, preferences.ErrSuppressed) {
+		return &pb.NotificationResponse{Message: "Notification suppressed by user preferences"}, nil
+	}
+	if errors.Is(err, handlers.ErrRecipientRateLimited) {
+		return nil, status.Error(codes.ResourceExhausted, "recipient rate limit exceeded")
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to save notification: %w", err)
 	}
-
-	log.Println("Notification saved to DB:", noti.ID.String())
-
-	// Enviar notificación por WebSocket si el usuario está conectado
-	notificationMessage := fmt.Sprintf(`{
-		"type": "notification",
-		"id": "%s",
-		"actorId": "%s",
-		"recipientId": "%s",
-		"notificationType": "%s",
-		"content": "%s",
-		"timestamp": "%s",
-		"read": false
-	}`, noti.ID, noti.ActorID, noti.RecipientID,
-		noti.Type, noti.Content, noti.Timestamp.Format(time.RFC3339))
-
-	// Intentar enviar por WebSocket (no falla si el usuario no está conectado)
-	log.Printf("Attempting to send WebSocket notification to responsibleId: %s", req.GetResponsibleId())
-	if err := handlers.SendNotification(req.GetResponsibleId(), notificationMessage); err != nil {
-		// Log pero no falla la operación
-		log.Printf("Could not send WebSocket notification to user %s: %v", req.GetResponsibleId(), err)
-	} else {
-		log.Printf("WebSocket notification sent successfully to user %s", req.GetResponsibleId())
+	if replayed {
+		grpc.SetHeader(ctx, metadata.Pairs("idempotent-replayed", "true"))
 	}
 
 	return &pb.NotificationResponse{
@@ -85,18 +93,60 @@ func (s *NotificationGRPCServer) FollowCreated(ctx context.Context, req *pb.Foll
 	}, nil
 }
 
-// StartGRPCServer arranca el servidor gRPC
+// StartGRPCServer arranca el servidor gRPC. Con GRPC_TLS_CERT_FILE/GRPC_TLS_KEY_FILE sirve TLS
+// (mTLS si además se define GRPC_TLS_CLIENT_CA_FILE); todas las llamadas pasan por el
+// interceptor de autenticación, la ACL por método y el límite de ingesta por servicio, salvo
+// grpc.health.v1. Si no puede abrir el puerto no termina el proceso: /readyz lo refleja.
 func StartGRPCServer() {
-	lis, err := net.Listen("tcp", ":50051")
+	const addr = ":50051"
+	tlsEnabled := config.GetEnv("GRPC_TLS_CERT_FILE", "") != ""
+
+	lis, err := net.Listen("tcp", addr)
 	if err != nil {
-		log.Fatalf("Failed to listen: %v", err)
+		slog.Error("Failed to listen for gRPC", "addr", addr, "error", err)
+		setListenerState(addr, tlsEnabled, false, err)
+		return
 	}
 
-	server := grpc.NewServer()
-	pb.RegisterNotificationServiceServer(server, &NotificationGRPCServer{})
+	auth := authenticatorFromEnv()
+	for _, method := range healthMethods {
+		auth.Public[method] = true
+	}
+	limiter := &RateLimiter{Public: auth.Public}
+	options := []grpc.ServerOption{
+		// Span por llamada con el traceparent que envíe el cliente en el metadata
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(LoggingUnaryInterceptor(), MetricsUnaryInterceptor(), auth.UnaryInterceptor(), limiter.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(LoggingStreamInterceptor(), MetricsStreamInterceptor(), auth.StreamInterceptor(), limiter.StreamInterceptor()),
+	}
 
-	log.Println("gRPC server listening on :50051")
-	if err := server.Serve(lis); err != nil {
-		log.Fatalf("Failed to serve gRPC: %v", err)
+	if certFile := config.GetEnv("GRPC_TLS_CERT_FILE", ""); certFile != "" {
+		caFile := config.GetEnv("GRPC_TLS_CLIENT_CA_FILE", "")
+		reloader, err := newCertReloader(certFile, config.GetEnv("GRPC_TLS_KEY_FILE", ""), caFile)
+		if err != nil {
+			logger.Fatal("Failed to load gRPC TLS certificates", "error", err)
+		}
+		options = append(options, grpc.Creds(credentials.NewTLS(reloader.TLSConfig())))
+		if caFile != "" {
+			slog.Info("gRPC mTLS enabled: client certificates are required")
+		} else {
+			slog.Info("gRPC TLS enabled")
+		}
+	} else {
+		slog.Warn("gRPC server running without TLS")
+	}
+
+	server := grpc.NewServer(options...)
+	pb.RegisterNotificationServiceServer(server, &NotificationGRPCServer{})
+	healthServer := registerHealth(server)
+	go watchReadiness(context.Background(), healthServer)
+
+	slog.Info("gRPC server listening", "addr", addr)
+	setListenerState(addr, tlsEnabled, true, nil)
+	err = server.Serve(lis)
+	if err == nil {
+		err = errors.New("gRPC server stopped")
 	}
+	slog.Error("gRPC server stopped serving", "error", err)
+	setListenerState(addr, tlsEnabled, false, err)
 }
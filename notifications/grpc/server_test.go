@@ -0,0 +1,29 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "notifications/proto/notificationpb"
+)
+
+func TestFollowCreatedRejectsInvalidUUIDs(t *testing.T) {
+	valid := uuid.NewString()
+	cases := map[string]*pb.FollowCreatedRequest{
+		"actorId":       {ActorId: "not-a-uuid", RecipeId: valid, ResponsibleId: valid},
+		"recipientId":   {ActorId: valid, RecipeId: "", ResponsibleId: valid},
+		"responsibleId": {ActorId: valid, RecipeId: valid, ResponsibleId: "123"},
+	}
+	for field, req := range cases {
+		t.Run(field, func(t *testing.T) {
+			_, err := (&NotificationGRPCServer{}).FollowCreated(context.Background(), req)
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("FollowCreated with invalid %s = %v, want InvalidArgument", field, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package grpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Espera entre intentos de recarga con los mismos ficheros: se duplica en cada fallo hasta el máximo
+const (
+	minReloadBackoff = time.Second
+	maxReloadBackoff = time.Minute
+)
+
+// certReloader sirve el certificado del servidor y la CA de clientes desde disco y los vuelve a
+// cargar cuando cambian, de modo que la rotación (cert-manager, secretos montados) no exige reiniciar
+type certReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string // vacío = TLS sin verificar certificados de cliente
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	modTimes map[string]time.Time
+
+	// Último intento de recarga: con los mismos ficheros no se repite hasta retryAt
+	attempted map[string]time.Time
+	retryAt   time.Time
+	backoff   time.Duration
+}
+
+func newCertReloader(certFile, keyFile, caFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile, caFile: caFile, modTimes: map[string]time.Time{}, backoff: minReloadBackoff}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// TLSConfig devuelve la configuración del servidor; con caFile exige y verifica el certificado
+// del cliente (mTLS)
+func (r *certReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, clientCA := r.current()
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				NextProtos:   []string{"h2"},
+			}
+			if clientCA != nil {
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+				cfg.ClientCAs = clientCA
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// current recarga los ficheros si cambiaron y devuelve el material vigente.
+// Si la recarga falla (p.ej. un fichero a medio escribir) se sigue usando el anterior y no se
+// reintenta en cada handshake: solo cuando los ficheros vuelven a cambiar o pasa el backoff.
+func (r *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	if r.reloadDue(time.Now()) {
+		if err := r.reload(); err != nil {
+			retryIn := r.reloadFailed()
+			slog.Error("Could not reload gRPC TLS certificates, keeping previous ones", "error", err, "retry_in", retryIn)
+		} else {
+			slog.Info("gRPC TLS certificates reloaded")
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert, r.clientCA
+}
+
+func (r *certReloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+	return files
+}
+
+// diskModTimes son las fechas de modificación actuales; un fichero que no existe queda en cero
+func (r *certReloader) diskModTimes() map[string]time.Time {
+	modTimes := map[string]time.Time{}
+	for _, file := range r.files() {
+		if info, err := os.Stat(file); err == nil {
+			modTimes[file] = info.ModTime()
+		}
+	}
+	return modTimes
+}
+
+// reloadDue indica si toca intentar una recarga: los ficheros difieren de los cargados y, si son
+// los mismos del último intento fallido, ya pasó su backoff. Reserva el intento para que los
+// handshakes concurrentes no recarguen a la vez.
+func (r *certReloader) reloadDue(now time.Time) bool {
+	modTimes := r.diskModTimes()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if sameModTimes(modTimes, r.modTimes) {
+		return false
+	}
+	if sameModTimes(modTimes, r.attempted) {
+		if now.Before(r.retryAt) {
+			return false
+		}
+	} else {
+		// Ficheros nuevos: se intenta enseguida y el backoff vuelve a empezar
+		r.backoff = minReloadBackoff
+	}
+	r.attempted = modTimes
+	r.retryAt = now.Add(r.backoff)
+	return true
+}
+
+// reloadFailed alarga el backoff del siguiente intento con los mismos ficheros y devuelve la espera
+func (r *certReloader) reloadFailed() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	retryIn := r.backoff
+	r.backoff = min(r.backoff*2, maxReloadBackoff)
+	return retryIn
+}
+
+func sameModTimes(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for file, modTime := range a {
+		if other, ok := b[file]; !ok || !other.Equal(modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *certReloader) reload() error {
+	modTimes := map[string]time.Time{}
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+
+	var clientCA *x509.CertPool
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("read client CA: %w", err)
+		}
+		clientCA = x509.NewCertPool()
+		if !clientCA.AppendCertsFromPEM(pem) {
+			return errors.New("client CA file contains no certificates")
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.clientCA = clientCA
+	r.modTimes = modTimes
+	return nil
+}
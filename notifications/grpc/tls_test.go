@@ -0,0 +1,97 @@
+package grpc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeKeyPair escribe un certificado autofirmado y su clave con la fecha de modificación dada
+func writeKeyPair(t *testing.T, certFile, keyFile, commonName string, modTime time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), modTime)
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), modTime)
+}
+
+func writeFile(t *testing.T, file string, data []byte, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func servedName(t *testing.T, r *certReloader) string {
+	t.Helper()
+	cert, _ := r.current()
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloaderBacksOffAfterFailedReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeKeyPair(t, certFile, keyFile, "v1", base)
+
+	r, err := newCertReloader(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Un certificado a medio escribir: se sigue sirviendo v1 y no se reintenta en cada handshake
+	writeFile(t, certFile, []byte("-----BEGIN CERTIFICATE-----\n"), base.Add(time.Minute))
+	for i := 0; i < 5; i++ {
+		if got := servedName(t, r); got != "v1" {
+			t.Fatalf("serving %q, want the last good certificate v1", got)
+		}
+	}
+	if r.backoff != 2*minReloadBackoff {
+		t.Errorf("backoff = %v after one failed attempt, want %v", r.backoff, 2*minReloadBackoff)
+	}
+
+	// Pasado el backoff se reintenta una vez y la espera se duplica
+	r.retryAt = time.Now().Add(-time.Millisecond)
+	servedName(t, r)
+	servedName(t, r)
+	if r.backoff != 4*minReloadBackoff {
+		t.Errorf("backoff = %v after two failed attempts, want %v", r.backoff, 4*minReloadBackoff)
+	}
+
+	// Ficheros nuevos se cargan enseguida, sin esperar al backoff
+	writeKeyPair(t, certFile, keyFile, "v2", base.Add(2*time.Minute))
+	if got := servedName(t, r); got != "v2" {
+		t.Errorf("serving %q after rotation, want v2", got)
+	}
+}
@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"notifications/config"
+	"notifications/dto"
+	"notifications/logger"
+	"notifications/middleware"
+	"notifications/models"
+	"notifications/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Acciones registradas en AdminAuditLogs
+const (
+	AdminActionListNotifications  = "notifications.list"
+	AdminActionDeleteNotification = "notification.delete"
+	AdminActionResendNotification = "notification.resend"
+	AdminActionViewConnection     = "connection.view"
+	AdminActionListAuditLog       = "audit_log.list"
+	AdminActionViewTimeline       = "notification.timeline"
+)
+
+// AdminRoles devuelve los roles del token con acceso a /admin (ADMIN_ROLES, separados por comas)
+func AdminRoles() []string {
+	var roles []string
+	for _, role := range strings.Split(config.GetEnv("ADMIN_ROLES", "admin,support"), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// AdminGetNotifications lista las notificaciones de cualquier usuario, sin agrupar.
+// Filtros: ?type=, ?read=true|false, ?archived=true|false, ?includeDeleted=true,
+// ?since= y ?until= (mismos formatos que los webhooks) y ?limit= (máximo 200).
+func AdminGetNotifications(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid userId format"})
+		return
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	query := config.DB.Where(`"responsibleId" = ?`, userUUID)
+	if c.Query("includeDeleted") == "true" {
+		query = query.Unscoped()
+	}
+	if notificationType := c.Query("type"); notificationType != "" {
+		query = query.Where("type = ?", notificationType)
+	}
+	for _, column := range []string{"read", "archived"} {
+		switch c.Query(column) {
+		case "":
+		case "true", "false":
+			query = query.Where(column+" = ?", c.Query(column) == "true")
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": column + " must be true or false"})
+			return
+		}
+	}
+	for param, condition := range map[string]string{"since": "timestamp >= ?", "until": "timestamp < ?"} {
+		if value := c.Query(param); value != "" {
+			t, err := utils.ParseTimestamp(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + " timestamp"})
+				return
+			}
+			query = query.Where(condition, t)
+		}
+	}
+
+	if err := recordAdminAction(config.DB, c, AdminActionListNotifications, &userUUID, nil, gin.H{"filters": c.Request.URL.Query()}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error writing audit log"})
+		return
+	}
+
+	var notifications []models.Notification
+	if err := query.Order("timestamp DESC").Limit(limit).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving notifications"})
+		return
+	}
+
+	response := make([]gin.H, 0, len(notifications))
+	for _, notification := range notifications {
+		entry := gin.H{
+			"id":            notification.ID,
+			"actorId":       notification.ActorID,
+			"recipientId":   notification.RecipientID,
+			"responsibleId": notification.ResponsibleID,
+			"type":          notification.Type,
+			"target":        notification.Target,
+			"content":       notification.Content,
+			"read":          notification.Read,
+			"archived":      notification.Archived,
+			"archivedAt":    notification.ArchivedAt,
+			"groupId":       notification.GroupID,
+			"dedupeKey":     notification.DedupeKey,
+			"traceId":       notification.TraceID,
+			"timestamp":     notification.Timestamp.Format(time.RFC3339),
+		}
+		if notification.DeletedAt.Valid {
+			entry["deletedAt"] = notification.DeletedAt.Time.Format(time.RFC3339)
+		}
+		response = append(response, entry)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": response,
+		"count":         len(response),
+	})
+}
+
+// AdminDeleteNotification elimina (borrado lógico) la notificación de cualquier usuario, o su grupo
+func AdminDeleteNotification(c *gin.Context) {
+	notification, req, ok := adminNotification(c)
+	if !ok {
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		err := changeNotificationOrGroup(tx, notification, false, models.NotificationEventDeleted,
+			"by admin "+middleware.UserID(c).String(), func(query *gorm.DB) error {
+				return query.Delete(&models.Notification{}).Error
+			})
+		if err != nil {
+			return err
+		}
+		return recordAdminAction(tx, c, AdminActionDeleteNotification, &notification.ResponsibleID, &notification.ID,
+			gin.H{"reason": req.Reason, "groupId": notification.GroupID})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notification deleted",
+		"id":      notification.ID,
+	})
+}
+
+// AdminResendNotification vuelve a enviar una notificación por el WebSocket de su destinatario.
+// Solo llega si el usuario está conectado a esta réplica; si no, responde 409.
+func AdminResendNotification(c *gin.Context) {
+	notification, req, ok := adminNotification(c)
+	if !ok {
+		return
+	}
+
+	payload := notificationPayload(notification)
+	if notification.GroupID != nil {
+		groups, err := loadGroups([]models.Notification{notification})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving notification group"})
+			return
+		}
+		if group, ok := groups[*notification.GroupID]; ok && group.Count > 1 {
+			payload = groupPayload(notification, group)
+		}
+	}
+	payload["resent"] = true
+
+	// La auditoría se escribe antes del envío: un reenvío sin registro no debe ocurrir
+	if err := recordAdminAction(config.DB, c, AdminActionResendNotification, &notification.ResponsibleID, &notification.ID,
+		gin.H{"reason": req.Reason}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error writing audit log"})
+		return
+	}
+
+	if err := SendNotification(c.Request.Context(), notification.ResponsibleID.String(), marshalMessage(payload), notification); err != nil {
+		if errors.Is(err, ErrUserNotConnected) {
+			c.JSON(http.StatusConflict, gin.H{"error": "user is not connected to this instance"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Error sending notification"})
+		return
+	}
+
+	slog.InfoContext(c.Request.Context(), "Admin resent notification", "notification_id", notification.ID, "user_id", notification.ResponsibleID)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notification re-sent",
+		"id":      notification.ID,
+	})
+}
+
+// AdminGetNotificationTimeline devuelve el historial de una notificación (creación, entregas por canal,
+// lectura, borrado...) en el orden en que se registró, aunque la notificación esté eliminada
+func AdminGetNotificationTimeline(c *gin.Context) {
+	notificationUUID, err := uuid.Parse(c.Param("notificationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notificationId format"})
+		return
+	}
+
+	var notification models.Notification
+	// Puede no existir ya: el historial se conserva aunque la notificación se haya purgado
+	err = config.DB.Unscoped().Where("id = ?", notificationUUID).First(&notification).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving notification"})
+		return
+	}
+
+	var targetUser *uuid.UUID
+	if notification.ID != uuid.Nil {
+		targetUser = &notification.ResponsibleID
+	}
+	if err := recordAdminAction(config.DB, c, AdminActionViewTimeline, targetUser, &notificationUUID, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error writing audit log"})
+		return
+	}
+
+	var events []models.NotificationEvent
+	if err := config.DB.Where(`"notificationId" = ?`, notificationUUID).Order("id").Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving notification timeline"})
+		return
+	}
+	if notification.ID == uuid.Nil && len(events) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	timeline := make([]gin.H, 0, len(events))
+	for _, event := range events {
+		entry := gin.H{
+			"event":     event.Event,
+			"createdAt": event.CreatedAt.Format(time.RFC3339Nano),
+		}
+		if event.Channel != "" {
+			entry["channel"] = event.Channel
+		}
+		if event.Result != "" {
+			entry["result"] = event.Result
+		}
+		if event.Detail != "" {
+			entry["detail"] = event.Detail
+		}
+		timeline = append(timeline, entry)
+	}
+
+	response := gin.H{
+		"notificationId": notificationUUID,
+		"events":         timeline,
+	}
+	if notification.ID != uuid.Nil {
+		response["userId"] = notification.ResponsibleID
+		response["type"] = notification.Type
+		response["read"] = notification.Read
+		response["archived"] = notification.Archived
+		response["deleted"] = notification.DeletedAt.Valid
+		response["timestamp"] = notification.Timestamp.Format(time.RFC3339)
+		response["traceId"] = notification.TraceID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// AdminGetConnection muestra el estado de conexión del usuario: si tiene WebSocket en esta réplica
+// y la presencia compartida en Postgres (última réplica que lo vio)
+func AdminGetConnection(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid userId format"})
+		return
+	}
+
+	if err := recordAdminAction(config.DB, c, AdminActionViewConnection, &userUUID, nil, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error writing audit log"})
+		return
+	}
+
+	response := gin.H{
+		"userId":            userUUID,
+		"connectedInstance": isConnected(userUUID.String()),
+		"presence":          nil,
+	}
+
+	var presence models.UserPresence
+	err = config.DB.Where(`"userId" = ?`, userUUID).First(&presence).Error
+	switch {
+	case err == nil:
+		response["presence"] = gin.H{
+			"connected":  presence.Connected,
+			"lastSeenAt": presence.LastSeenAt.Format(time.RFC3339),
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving presence"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// AdminGetAuditLog lista el registro de auditoría, del más reciente al más antiguo.
+// Filtros: ?adminId=, ?userId=, ?action= y ?limit= (máximo 200).
+func AdminGetAuditLog(c *gin.Context) {
+	limit := 50
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	query := config.DB.Model(&models.AdminAuditLog{})
+	for param, column := range map[string]string{"adminId": `"adminId"`, "userId": `"targetUserId"`} {
+		if value := c.Query(param); value != "" {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + " format"})
+				return
+			}
+			query = query.Where(column+" = ?", id)
+		}
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+
+	if err := recordAdminAction(config.DB, c, AdminActionListAuditLog, nil, nil, gin.H{"filters": c.Request.URL.Query()}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error writing audit log"})
+		return
+	}
+
+	var entries []models.AdminAuditLog
+	if err := query.Order(`"createdAt" DESC`).Limit(limit).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving audit log"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, gin.H{
+			"id":             entry.ID,
+			"adminId":        entry.AdminID,
+			"action":         entry.Action,
+			"targetUserId":   entry.TargetUserID,
+			"notificationId": entry.NotificationID,
+			"details":        json.RawMessage(entry.Details),
+			"requestId":      entry.RequestID,
+			"createdAt":      entry.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// adminNotification carga la notificación de :notificationId sin comprobar el dueño y lee el
+// cuerpo opcional con el motivo de la acción
+func adminNotification(c *gin.Context) (models.Notification, dto.AdminActionRequest, bool) {
+	var (
+		notification models.Notification
+		req          dto.AdminActionRequest
+	)
+
+	notificationUUID, err := uuid.Parse(c.Param("notificationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notificationId format"})
+		return notification, req, false
+	}
+
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return notification, req, false
+		}
+	}
+
+	if err := config.DB.Where("id = ?", notificationUUID).First(&notification).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return notification, req, false
+	}
+	return notification, req, true
+}
+
+// recordAdminAction escribe una entrada en AdminAuditLogs con el usuario del token como autor.
+// Recibe tx para que las acciones que modifican datos se auditen en la misma transacción.
+func recordAdminAction(tx *gorm.DB, c *gin.Context, action string, targetUser, notificationID *uuid.UUID, details gin.H) error {
+	if details == nil {
+		details = gin.H{}
+	}
+	data, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	entry := models.AdminAuditLog{
+		ID:             uuid.New(),
+		AdminID:        middleware.UserID(c),
+		Action:         action,
+		TargetUserID:   targetUser,
+		NotificationID: notificationID,
+		Details:        string(data),
+		RequestID:      logger.RequestID(c.Request.Context()),
+		CreatedAt:      time.Now(),
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		slog.ErrorContext(c.Request.Context(), "Could not write admin audit log", "action", action, "error", err)
+		return err
+	}
+	return nil
+}
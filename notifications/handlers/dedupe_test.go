@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"notifications/clock"
+	"notifications/internal/testdb"
+	"notifications/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestDedupeConflictOnlyMergesActiveNotifications(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	fake := clock.NewFake(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	useTestDB(t, db, fake)
+
+	actor, user := uuid.New(), uuid.New()
+	follow := func() models.Notification {
+		t.Helper()
+		noti := models.Notification{
+			ActorID:       actor,
+			RecipientID:   user,
+			ResponsibleID: user,
+			Type:          "follow",
+			Content:       "started following you",
+			Timestamp:     fake.Now(),
+			DedupeKey:     DedupeKey(actor.String(), user.String(), "follow", "started following you"),
+		}
+		if err := CreateNotification(ctx, &noti, DedupeConflict()); err != nil {
+			t.Fatalf("CreateNotification: %v", err)
+		}
+		return noti
+	}
+	outboxEvents := func(noti models.Notification) int64 {
+		t.Helper()
+		var count int64
+		db.Model(&models.OutboxEvent{}).Where(`"notificationId" = ?`, noti.ID).Count(&count)
+		return count
+	}
+
+	// Un follow repetido sobre una notificación leída y archivada la renueva como no leída
+	first := follow()
+	if err := db.Model(&first).Updates(map[string]interface{}{"read": true, "archived": true, "archivedAt": fake.Now()}).Error; err != nil {
+		t.Fatal(err)
+	}
+	fake.Advance(time.Hour)
+	again := follow()
+	if again.ID != first.ID {
+		t.Fatalf("repeated follow created %s, want it merged into %s", again.ID, first.ID)
+	}
+	var merged models.Notification
+	if err := db.First(&merged, "id = ?", first.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if merged.Read || merged.Archived || merged.ArchivedAt != nil {
+		t.Errorf("merged notification read=%v archived=%v archivedAt=%v, want it unread and unarchived",
+			merged.Read, merged.Archived, merged.ArchivedAt)
+	}
+	if !merged.Timestamp.Equal(fake.Now()) {
+		t.Errorf("merged timestamp = %s, want %s", merged.Timestamp, fake.Now())
+	}
+
+	// Tras borrarla, un nuevo follow crea otra notificación que sí se entrega
+	if err := db.Delete(&models.Notification{}, "id = ?", first.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	refollow := follow()
+	if refollow.ID == first.ID {
+		t.Fatal("follow after delete was merged into the deleted notification")
+	}
+	var created int64
+	db.Model(&models.NotificationEvent{}).
+		Where(`"notificationId" = ? AND event = ?`, refollow.ID, models.NotificationEventCreated).Count(&created)
+	if created != 1 {
+		t.Errorf("refollow has %d created events, want 1", created)
+	}
+	if n := outboxEvents(refollow); n == 0 {
+		t.Error("refollow was not queued for delivery")
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"notifications/config"
+	"notifications/dto"
+	"notifications/middleware"
+	"notifications/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// RegisterDevice registra (o reasigna) un token de push para el usuario del token JWT
+func RegisterDevice(c *gin.Context) {
+	userUUID := middleware.UserID(c)
+
+	var req dto.RegisterDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Un token pertenece a un único dispositivo: si otro usuario inicia sesión en él, se reasigna
+	device := models.DeviceToken{
+		ID:        uuid.New(),
+		UserID:    userUUID,
+		Token:     req.Token,
+		Platform:  req.Platform,
+		CreatedAt: time.Now(),
+	}
+	if err := config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token"}},
+		DoUpdates: clause.AssignmentColumns([]string{"userId", "platform"}),
+	}).Create(&device).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error registering device"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Device registered",
+		"id":       device.ID,
+		"platform": device.Platform,
+	})
+}
+
+// UnregisterDevice elimina un token de push del usuario del token JWT
+func UnregisterDevice(c *gin.Context) {
+	userUUID := middleware.UserID(c)
+
+	result := config.DB.Where(`token = ? AND "userId" = ?`, c.Param("token"), userUUID).Delete(&models.DeviceToken{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error removing device"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found or unauthorized"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Device removed"})
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"notifications/config"
+	"notifications/lifecycle"
+	"notifications/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// groupWindow es la ventana de agrupación configurada; 0 desactiva la agrupación
+func groupWindow() time.Duration {
+	return config.GetDurationEnv("NOTIFICATION_GROUP_WINDOW", time.Hour)
+}
+
+// aggregateNotification asigna la notificación a un grupo abierto con el mismo
+// (responsibleId, type, target) o crea uno nuevo. Debe llamarse dentro de una transacción.
+func aggregateNotification(tx *gorm.DB, noti *models.Notification) (*models.NotificationGroup, error) {
+	window := groupWindow()
+	if window <= 0 {
+		return nil, nil
+	}
+
+	// Serializa la agregación por clave para que dos eventos simultáneos no creen dos grupos
+	key := noti.ResponsibleID.String() + "|" + noti.Type + "|" + noti.Target
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", key).Error; err != nil {
+		return nil, err
+	}
+
+	var group models.NotificationGroup
+	isNew := false
+	err := tx.Where(`"responsibleId" = ? AND type = ? AND target = ? AND "firstAt" >= ?`,
+		noti.ResponsibleID, noti.Type, noti.Target, noti.Timestamp.Add(-window)).
+		Order(`"firstAt" DESC`).
+		First(&group).Error
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		isNew = true
+		group = models.NotificationGroup{
+			ID:            uuid.New(),
+			ResponsibleID: noti.ResponsibleID,
+			Type:          noti.Type,
+			Target:        noti.Target,
+			FirstAt:       noti.Timestamp,
+		}
+	case err != nil:
+		return nil, err
+	}
+
+	groupID := group.ID
+	noti.GroupID = &groupID
+	if err := tx.Model(noti).Update("groupId", group.ID).Error; err != nil {
+		return nil, err
+	}
+
+	var actorCount int64
+	if err := tx.Model(&models.Notification{}).
+		Where(`"groupId" = ?`, group.ID).
+		Distinct(`"actorId"`).
+		Count(&actorCount).Error; err != nil {
+		return nil, err
+	}
+
+	group.AddActor(noti.ActorID)
+	group.ActorCount = int(actorCount)
+	group.Count++
+	group.LatestNotificationID = noti.ID
+	if noti.Timestamp.After(group.LastAt) {
+		group.LastAt = noti.Timestamp
+	}
+
+	if isNew {
+		err = tx.Create(&group).Error
+	} else {
+		err = tx.Save(&group).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// latestOfGroupScope limita la consulta a notificaciones sin grupo o a la más reciente de cada grupo
+// entre las que cumplen los mismos filtros que el listado: con unread=true, si la última del grupo ya
+// está leída, el grupo aparece con la última sin leer en vez de desaparecer. La subconsulta se
+// correlaciona por "groupId" (un grupo es de un solo usuario) y usa "idx_Notifications_groupId_timestamp".
+func latestOfGroupScope(onlyUnread, includeArchived, excludeHeld bool) func(*gorm.DB) *gorm.DB {
+	newer := []string{
+		`newer."groupId" = "Notifications"."groupId"`,
+		`newer."deletedAt" IS NULL`,
+		`(newer.timestamp, newer.id) > ("Notifications".timestamp, "Notifications".id)`,
+	}
+	if onlyUnread {
+		newer = append(newer, `newer.read = FALSE`)
+	}
+	if !includeArchived {
+		newer = append(newer, `newer.archived = FALSE`)
+	}
+	if excludeHeld {
+		newer = append(newer, `newer.id NOT IN (SELECT "notificationId" FROM "DigestItems" WHERE "deliveredAt" IS NULL)`)
+	}
+	condition := `("groupId" IS NULL OR NOT EXISTS (SELECT 1 FROM "Notifications" AS newer WHERE ` +
+		strings.Join(newer, " AND ") + `))`
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(condition)
+	}
+}
+
+// notificationOrGroup restringe la consulta a la notificación o, si está agrupada, a todo su grupo
+func notificationOrGroup(db *gorm.DB, noti models.Notification) *gorm.DB {
+	if noti.GroupID != nil {
+		return db.Where(`"groupId" = ?`, *noti.GroupID)
+	}
+	return db.Where("id = ?", noti.ID)
+}
+
+// changeNotificationOrGroup aplica change a la notificación (o a todo su grupo) y registra event en el
+// historial de cada notificación afectada. Debe llamarse dentro de una transacción; unscoped incluye
+// las notificaciones eliminadas.
+func changeNotificationOrGroup(tx *gorm.DB, noti models.Notification, unscoped bool, event, detail string, change func(*gorm.DB) error) error {
+	scope := func() *gorm.DB {
+		query := tx.Model(&models.Notification{})
+		if unscoped {
+			query = query.Unscoped()
+		}
+		return notificationOrGroup(query, noti)
+	}
+
+	var ids []uuid.UUID
+	if err := scope().Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if err := change(scope()); err != nil {
+		return err
+	}
+	return lifecycle.RecordMany(tx, ids, noti.ResponsibleID, event, detail)
+}
+
+// loadGroups obtiene los grupos referenciados por las notificaciones indexados por id
+func loadGroups(notifications []models.Notification) (map[uuid.UUID]models.NotificationGroup, error) {
+	var ids []uuid.UUID
+	for _, n := range notifications {
+		if n.GroupID != nil {
+			ids = append(ids, *n.GroupID)
+		}
+	}
+
+	groups := make(map[uuid.UUID]models.NotificationGroup, len(ids))
+	if len(ids) == 0 {
+		return groups, nil
+	}
+
+	var rows []models.NotificationGroup
+	if err := config.DB.Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, g := range rows {
+		groups[g.ID] = g
+	}
+	return groups, nil
+}
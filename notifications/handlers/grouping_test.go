@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"notifications/internal/testdb"
+	"notifications/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestGroupedListingShowsLatestMatchingMember(t *testing.T) {
+	db := testdb.Open(t)
+	base := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	user, other := uuid.New(), uuid.New()
+
+	// Un grupo de tres likes del mismo post: la más reciente ya está leída y la intermedia archivada
+	group := models.NotificationGroup{ID: uuid.New(), ResponsibleID: user, Type: "like", Target: "post", Count: 3, FirstAt: base, LastAt: base.Add(2 * time.Minute)}
+	member := func(responsible uuid.UUID, groupID *uuid.UUID, at time.Time, read, archived bool) models.Notification {
+		t.Helper()
+		noti := models.Notification{
+			ActorID:       uuid.New(),
+			RecipientID:   uuid.New(),
+			ResponsibleID: responsible,
+			Type:          "like",
+			Content:       "grouped",
+			Target:        "post",
+			Read:          read,
+			Archived:      archived,
+			Timestamp:     at,
+			GroupID:       groupID,
+		}
+		if err := db.Create(&noti).Error; err != nil {
+			t.Fatal(err)
+		}
+		return noti
+	}
+	oldest := member(user, &group.ID, base, false, false)
+	archived := member(user, &group.ID, base.Add(time.Minute), false, true)
+	latest := member(user, &group.ID, base.Add(2*time.Minute), true, false)
+	group.LatestNotificationID = latest.ID
+	if err := db.Create(&group).Error; err != nil {
+		t.Fatal(err)
+	}
+	ungrouped := member(user, nil, base.Add(-time.Hour), false, false)
+
+	// Otro usuario con su propio grupo, más reciente: no debe afectar al listado
+	otherGroup := models.NotificationGroup{ID: uuid.New(), ResponsibleID: other, Type: "like", Target: "post", Count: 1, FirstAt: base, LastAt: base.Add(time.Hour)}
+	otherLatest := member(other, &otherGroup.ID, base.Add(time.Hour), false, false)
+	otherGroup.LatestNotificationID = otherLatest.ID
+	if err := db.Create(&otherGroup).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	list := func(query *gorm.DB) []uuid.UUID {
+		t.Helper()
+		var notifications []models.Notification
+		if err := query.Find(&notifications).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]uuid.UUID, 0, len(notifications))
+		for _, noti := range notifications {
+			ids = append(ids, noti.ID)
+		}
+		return ids
+	}
+	expect := func(name string, got []uuid.UUID, want ...uuid.UUID) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Errorf("%s = %v, want %v", name, got, want)
+			return
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s = %v, want %v", name, got, want)
+				return
+			}
+		}
+	}
+
+	expect("grouped", list(listNotificationsQuery(db, user, false, false, true)), latest.ID, ungrouped.ID)
+	// La última está leída: el grupo sigue apareciendo con la última sin leer y no archivada
+	expect("grouped unread", list(listNotificationsQuery(db, user, true, false, true)), oldest.ID, ungrouped.ID)
+	expect("grouped unread including archived", list(listNotificationsQuery(db, user, true, true, true)), archived.ID, ungrouped.ID)
+	expect("pending on connect", list(pendingNotificationsQuery(db, user, base.AddDate(0, 0, -30))), oldest.ID, ungrouped.ID)
+
+	// Si la última se elimina, el grupo se representa con la anterior
+	if err := db.Delete(&latest).Error; err != nil {
+		t.Fatal(err)
+	}
+	expect("grouped after deleting the latest", list(listNotificationsQuery(db, user, false, false, true)), oldest.ID, ungrouped.ID)
+}
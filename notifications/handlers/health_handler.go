@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"notifications/config"
+	"notifications/health"
+	"notifications/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Comprobaciones de /livez y /readyz; las registra main antes de arrancar los servidores.
+// Liveness solo mira el proceso; readiness añade las dependencias y los latidos de los workers.
+var (
+	LivenessChecks  = map[string]health.Check{}
+	ReadinessChecks = map[string]health.Check{}
+)
+
+// HealthCheckTimeout es el tiempo máximo de cada comprobación
+func HealthCheckTimeout() time.Duration {
+	return config.GetDurationEnv("HEALTH_CHECK_TIMEOUT", 2*time.Second)
+}
+
+// Livez responde 200 si el proceso está vivo; no comprueba dependencias ni workers
+func Livez(c *gin.Context) {
+	respondHealth(c, LivenessChecks)
+}
+
+// Readyz responde 200 si el servicio puede atender tráfico (base de datos, gRPC, pub/sub y workers);
+// 503 con el detalle de cada comprobación en caso contrario
+func Readyz(c *gin.Context) {
+	respondHealth(c, ReadinessChecks)
+}
+
+func respondHealth(c *gin.Context, checks map[string]health.Check) {
+	healthy, results := health.Run(c.Request.Context(), checks, HealthCheckTimeout())
+
+	status, code := health.StatusOK, http.StatusOK
+	if !healthy {
+		status, code = health.StatusFail, http.StatusServiceUnavailable
+		for name, result := range results {
+			if result.Status != health.StatusOK {
+				slog.WarnContext(c.Request.Context(), "Health check failed", "check", name, "path", c.FullPath(), "error", result.Error)
+			}
+		}
+	}
+	c.JSON(code, gin.H{"status": status, "checks": results})
+}
+
+// DatabaseCheck hace ping a Postgres; la latencia del ping es la de la comprobación
+func DatabaseCheck(ctx context.Context) (map[string]interface{}, error) {
+	sqlDB, err := config.DB.DB()
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return nil, err
+	}
+
+	stats := sqlDB.Stats()
+	return map[string]interface{}{
+		"openConnections": stats.OpenConnections,
+		"inUse":           stats.InUse,
+		"idle":            stats.Idle,
+		"waitCount":       stats.WaitCount,
+	}, nil
+}
+
+// PubSubCheck comprueba el backend de entrega entre réplicas: el outbox en Postgres que consume el relay
+// y las conexiones WebSocket locales. El retraso del outbox se informa pero no hace fallar la comprobación,
+// para no sacar de servicio todas las réplicas a la vez por un pico de carga.
+func PubSubCheck(ctx context.Context) (map[string]interface{}, error) {
+	var backlog struct {
+		Pending int64
+		Oldest  *time.Time
+	}
+	err := config.DB.WithContext(ctx).Model(&models.OutboxEvent{}).
+		Select(`COUNT(*) AS pending, MIN("nextAttemptAt") AS oldest`).
+		Where("status = ?", models.OutboxStatusPending).
+		Scan(&backlog).Error
+	if err != nil {
+		return nil, err
+	}
+
+	connectionsMu.RLock()
+	connections := len(Connections)
+	connectionsMu.RUnlock()
+
+	detail := map[string]interface{}{
+		"backend":          "postgres-outbox",
+		"relay":            Relay != nil,
+		"pendingEvents":    backlog.Pending,
+		"localConnections": connections,
+	}
+	if backlog.Oldest != nil {
+		detail["oldestPendingSeconds"] = max(time.Since(*backlog.Oldest), 0).Round(time.Millisecond).Seconds()
+	}
+	return detail, nil
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"notifications/config"
+	"notifications/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// IdempotencyKeyHeader es el header HTTP con la clave de idempotencia del productor
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	maxIdempotencyKeyLength = 255
+)
+
+var ErrIdempotencyKeyTooLong = errors.New("idempotency key too long")
+
+// idempotencyTTL es el tiempo durante el cual una clave devuelve el resultado original
+func idempotencyTTL() time.Duration {
+	return config.GetDurationEnv("IDEMPOTENCY_KEY_TTL", 24*time.Hour)
+}
+
+// claimIdempotencyKey intenta reservar la clave dentro de la transacción. Si ya existía
+// (y no ha expirado) carga la notificación original en noti y devuelve true.
+// Una petición concurrente con la misma clave espera en el INSERT hasta que la primera confirme.
+func claimIdempotencyKey(tx *gorm.DB, idem *models.IdempotencyKey, noti *models.Notification) (bool, error) {
+	if err := tx.Where(`scope = ? AND "key" = ? AND "expiresAt" < ?`, idem.Scope, idem.Key, idem.CreatedAt).
+		Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return false, err
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(idem)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return false, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := tx.Where(`scope = ? AND "key" = ?`, idem.Scope, idem.Key).First(&existing).Error; err != nil {
+		return false, err
+	}
+	if existing.NotificationID == nil {
+		return false, errors.New("idempotency key has no stored result")
+	}
+	if err := tx.Unscoped().First(noti, "id = ?", *existing.NotificationID).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PurgeExpiredIdempotencyKeys borra periódicamente las claves expiradas
+func PurgeExpiredIdempotencyKeys(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		result := config.DB.Where(`"expiresAt" < ?`, time.Now()).Delete(&models.IdempotencyKey{})
+		if result.Error != nil {
+			slog.Error("Error purging expired idempotency keys", "error", result.Error)
+			continue
+		}
+		if result.RowsAffected > 0 {
+			slog.Info("Purged expired idempotency keys", "count", result.RowsAffected)
+		}
+	}
+}
+
+// DedupeKey calcula la clave de deduplicación de una notificación a partir de sus partes
+func DedupeKey(parts ...string) *string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	key := hex.EncodeToString(sum[:])
+	return &key
+}
+
+// DedupeConflict es el ON CONFLICT de las notificaciones con dedupeKey: una repetición actualiza la
+// notificación activa con la misma clave y la vuelve a marcar como no leída y no archivada. El índice
+// único solo cubre las no eliminadas ("deletedAt" IS NULL), así que tras un borrado se crea otra.
+func DedupeConflict() clause.OnConflict {
+	return clause.OnConflict{
+		Columns:     []clause.Column{{Name: "dedupeKey"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: `"deletedAt" IS NULL`}}},
+		DoUpdates: append(clause.AssignmentColumns([]string{"timestamp"}),
+			clause.Assignment{Column: clause.Column{Name: "read"}, Value: false},
+			clause.Assignment{Column: clause.Column{Name: "archived"}, Value: false},
+			clause.Assignment{Column: clause.Column{Name: "archivedAt"}, Value: nil},
+		),
+	}
+}
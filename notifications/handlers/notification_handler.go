@@ -1,60 +1,59 @@
 package handlers
 
 import (
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"notifications/config"
 	"notifications/dto"
+	"notifications/middleware"
 	"notifications/models"
 	"notifications/utils"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+// WebhookLike es el endpoint original de likes; los eventos nuevos usan POST /webhook/:source
 func WebhookLike(c *gin.Context) {
 	var req dto.LikeWebhookRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
+		countWebhookEvent("like.created", "invalid")
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Debug: Log de los datos recibidos
-	log.Printf("=== WEBHOOK RECEIVED ===")
-	log.Printf("Event: %s", req.Event)
-	log.Printf("ActorId: %s", req.Data.ActorId)
-	log.Printf("RecipientId: %s", req.Data.RecipientId)
-	log.Printf("ResponsibleId: %s", req.Data.ResponsibleId)
-	log.Printf("Type: %s", req.Data.Type)
-	log.Printf("Content: %s", req.Data.Content)
-	log.Printf("========================")
+	// Solo metadatos: el contenido y los ids de usuario no se registran
+	slog.DebugContext(c.Request.Context(), "Like webhook received", "event", req.Event, "type", req.Data.Type)
 
 	actorUUID, err := uuid.Parse(req.Data.ActorId)
 	if err != nil {
+		countWebhookEvent("like.created", "invalid")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid actorId UUID"})
 		return
 	}
 
 	recipientUUID, err := uuid.Parse(req.Data.RecipientId)
 	if err != nil {
+		countWebhookEvent("like.created", "invalid")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recipientId UUID"})
 		return
 	}
 
 	responsibleUUID, err := uuid.Parse(req.Data.ResponsibleId)
 	if err != nil {
+		countWebhookEvent("like.created", "invalid")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid responsibleId UUID"})
 		return
 	}
 
-	// Parse timestamp compatible with ISO format from Python
-	parsedTime, err := time.Parse("2006-01-02T15:04:05.999999", req.Data.Timestamp)
+	// RFC3339, ISO sin zona de Python o epoch; siempre se guarda en UTC
+	parsedTime, err := utils.ParseTimestamp(req.Data.Timestamp)
 	if err != nil {
+		countWebhookEvent("like.created", "invalid")
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timestamp format"})
 		return
 	}
@@ -65,41 +64,29 @@ func WebhookLike(c *gin.Context) {
 		ResponsibleID: responsibleUUID,
 		Type:          req.Data.Type,
 		Content:       req.Data.Content,
+		Target:        req.Data.TargetId,
 		Read:          false,
 		Timestamp:     parsedTime,
 	}
 
-	if err := config.DB.Create(&notification).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving notification"})
-		return
-	}
-
-	// Enviar notificación por WebSocket si el usuario está conectado
-	notificationMessage := fmt.Sprintf(`{
-		"type": "notification",
-		"id": "%s",
-		"actorId": "%s",
-		"recipientId": "%s",
-		"notificationType": "%s",
-		"content": "%s",
-		"timestamp": "%s",
-		"read": false
-	}`, notification.ID, notification.ActorID, notification.RecipientID,
-		notification.Type, notification.Content, notification.Timestamp.Format(time.RFC3339))
+	storeWebhookNotification(c, "webhook:like", "like.created", &notification)
+}
 
-	// Intentar enviar por WebSocket (no falla si el usuario no está conectado)
-	log.Printf("Attempting to send WebSocket notification to responsibleId: %s", req.Data.ResponsibleId)
-	if err := SendNotification(req.Data.ResponsibleId, notificationMessage); err != nil {
-		// Log pero no falla la operación
-		log.Printf("Could not send WebSocket notification to user %s: %v", req.Data.ResponsibleId, err)
-	} else {
-		log.Printf("WebSocket notification sent successfully to user %s", req.Data.ResponsibleId)
+// listNotificationsQuery construye el listado de GetNotifications usando ResponsibleID (GORM excluye
+// las eliminadas). Debe seguir cubierto por los índices de la migración 0005 (y el de grupos de la 0021).
+func listNotificationsQuery(db *gorm.DB, userUUID uuid.UUID, onlyUnread, includeArchived, grouped bool) *gorm.DB {
+	query := db.Where(`"responsibleId" = ?`, userUUID)
+	if onlyUnread {
+		query = query.Where("read = ?", false)
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Notification created successfully!",
-		"id":      notification.ID,
-	})
+	if !includeArchived {
+		query = query.Where("archived = ?", false)
+	}
+	if grouped {
+		// Solo la más reciente de cada grupo; el resto se resume en count/actors
+		query = query.Scopes(latestOfGroupScope(onlyUnread, includeArchived, false))
+	}
+	return query.Order("timestamp DESC")
 }
 
 // GetNotifications obtiene las notificaciones de un usuario desde la base de datos
@@ -113,35 +100,9 @@ func GetNotifications(c *gin.Context) {
 		return
 	}
 
-	// Validar token de autorización
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing authorization header"})
-		return
-	}
-
-	tokenParts := strings.Split(authHeader, " ")
-	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
-		return
-	}
-
-	tokenStr := tokenParts[1]
-	claims, err := utils.ParseJWT(tokenStr)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-		return
-	}
-
-	tokenUserId, ok := claims["userId"].(string)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token payload"})
-		return
-	}
-
 	// Verificar que el usuario del token coincida con el solicitado
-	if tokenUserId != userIdParam {
-		c.JSON(http.StatusForbidden, gin.H{"error": "unauthorized to access these notifications"})
+	if middleware.UserID(c) != userUUID {
+		middleware.Forbidden(c, "unauthorized to access these notifications")
 		return
 	}
 
@@ -154,32 +115,48 @@ func GetNotifications(c *gin.Context) {
 	}
 
 	onlyUnread := c.Query("unread") == "true"
-
-	// Construir la consulta usando ResponsibleID
-	query := config.DB.Where(`"responsibleId" = ?`, userUUID)
-	if onlyUnread {
-		query = query.Where("read = ?", false)
-	}
+	includeArchived := c.Query("includeArchived") == "true"
+	grouped := c.Query("grouped") != "false"
 
 	var notifications []models.Notification
-	if err := query.Order("timestamp DESC").Limit(limit).Find(&notifications).Error; err != nil {
+	if err := listNotificationsQuery(config.DB, userUUID, onlyUnread, includeArchived, grouped).
+		Limit(limit).Find(&notifications).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving notifications"})
 		return
 	}
 
+	groups := map[uuid.UUID]models.NotificationGroup{}
+	if grouped {
+		if groups, err = loadGroups(notifications); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving notification groups"})
+			return
+		}
+	}
+
 	// Formatear las notificaciones para la respuesta
 	var response []gin.H
 	for _, notification := range notifications {
-		response = append(response, gin.H{
+		entry := gin.H{
 			"id":            notification.ID,
 			"actorId":       notification.ActorID,
 			"recipientId":   notification.RecipientID,
 			"responsibleId": notification.ResponsibleID,
 			"type":          notification.Type,
+			"target":        notification.Target,
 			"content":       notification.Content,
 			"read":          notification.Read,
+			"archived":      notification.Archived,
 			"timestamp":     notification.Timestamp.Format(time.RFC3339),
-		})
+		}
+		if notification.GroupID != nil {
+			entry["groupId"] = notification.GroupID
+			if group, ok := groups[*notification.GroupID]; ok {
+				entry["count"] = group.Count
+				entry["actorCount"] = group.ActorCount
+				entry["actors"] = group.ActorIDs.Strings()
+			}
+		}
+		response = append(response, entry)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -199,47 +176,133 @@ func MarkNotificationAsRead(c *gin.Context) {
 		return
 	}
 
-	// Validar token de autorización
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing authorization header"})
+	userUUID := middleware.UserID(c)
+
+	// Buscar la notificación y verificar que pertenece al usuario
+	var notification models.Notification
+	if err := config.DB.Where(`id = ? AND "responsibleId" = ?`, notificationUUID, userUUID).First(&notification).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found or unauthorized"})
+		return
+	}
+
+	// Marcar como leída (todo el grupo si la notificación está agrupada)
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		return changeNotificationOrGroup(tx, notification, false, models.NotificationEventRead, "", func(query *gorm.DB) error {
+			return query.Update("read", true).Error
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notification marked as read",
+		"id":      notification.ID,
+	})
+}
+
+// DeleteNotification elimina (borrado lógico) una notificación del usuario
+func DeleteNotification(c *gin.Context) {
+	notificationUUID, err := uuid.Parse(c.Param("notificationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notificationId format"})
+		return
+	}
+
+	userUUID := middleware.UserID(c)
+
+	var notification models.Notification
+	if err := config.DB.Where(`id = ? AND "responsibleId" = ?`, notificationUUID, userUUID).First(&notification).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found or unauthorized"})
 		return
 	}
 
-	tokenParts := strings.Split(authHeader, " ")
-	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		return changeNotificationOrGroup(tx, notification, false, models.NotificationEventDeleted, "", func(query *gorm.DB) error {
+			return query.Delete(&models.Notification{}).Error
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting notification"})
 		return
 	}
 
-	tokenStr := tokenParts[1]
-	claims, err := utils.ParseJWT(tokenStr)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notification deleted",
+		"id":      notification.ID,
+	})
+}
+
+// ArchiveNotification archiva una notificación para ocultarla del listado por defecto
+func ArchiveNotification(c *gin.Context) {
+	notificationUUID, err := uuid.Parse(c.Param("notificationId"))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notificationId format"})
 		return
 	}
 
-	tokenUserId, ok := claims["userId"].(string)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token payload"})
+	userUUID := middleware.UserID(c)
+
+	var notification models.Notification
+	if err := config.DB.Where(`id = ? AND "responsibleId" = ?`, notificationUUID, userUUID).First(&notification).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found or unauthorized"})
 		return
 	}
 
-	// Buscar la notificación y verificar que pertenece al usuario
+	now := time.Now()
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		return changeNotificationOrGroup(tx, notification, false, models.NotificationEventArchived, "", func(query *gorm.DB) error {
+			return query.Updates(map[string]interface{}{
+				"archived":   true,
+				"archivedAt": now,
+			}).Error
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error archiving notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notification archived",
+		"id":      notification.ID,
+	})
+}
+
+// RestoreNotification recupera una notificación eliminada o archivada
+func RestoreNotification(c *gin.Context) {
+	notificationUUID, err := uuid.Parse(c.Param("notificationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notificationId format"})
+		return
+	}
+
+	userUUID := middleware.UserID(c)
+
+	// Unscoped para poder encontrar también las notificaciones eliminadas
 	var notification models.Notification
-	if err := config.DB.Where(`id = ? AND "responsibleId" = ?`, notificationUUID, tokenUserId).First(&notification).Error; err != nil {
+	if err := config.DB.Unscoped().Where(`id = ? AND "responsibleId" = ?`, notificationUUID, userUUID).First(&notification).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found or unauthorized"})
 		return
 	}
 
-	// Marcar como leída
-	if err := config.DB.Model(&notification).Update("read", true).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating notification"})
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		return changeNotificationOrGroup(tx, notification, true, models.NotificationEventRestored, "", func(query *gorm.DB) error {
+			return query.Updates(map[string]interface{}{
+				"archived":   false,
+				"archivedAt": nil,
+				"deletedAt":  nil,
+			}).Error
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error restoring notification"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Notification marked as read",
+		"message": "Notification restored",
 		"id":      notification.ID,
 	})
 }
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"notifications/internal/testdb"
+	"notifications/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// seedNotifications inserta 50.000 notificaciones repartidas entre 500 usuarios (100 por usuario) a lo
+// largo de ~35 días, con una de cada cinco sin leer, y actualiza las estadísticas del planificador
+func seedNotifications(t testing.TB, db *gorm.DB) {
+	t.Helper()
+	err := db.Exec(`INSERT INTO "Notifications"
+		("actorId", "recipientId", "responsibleId", type, content, read, archived, timestamp)
+		SELECT gen_random_uuid(), gen_random_uuid(),
+			('00000000-0000-0000-0000-' || lpad((g % 500)::text, 12, '0'))::uuid,
+			'like', 'seed', g % 5 <> 0, g % 17 = 0, NOW() - make_interval(mins => g)
+		FROM generate_series(1, 50000) AS g`).Error
+	if err != nil {
+		t.Fatalf("seed notifications: %v", err)
+	}
+	if err := db.Exec(`ANALYZE`).Error; err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+}
+
+// explain devuelve el plan de la consulta que GORM genera para query
+func explain(t testing.TB, db *gorm.DB, query func(tx *gorm.DB) *gorm.DB) string {
+	t.Helper()
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		return query(tx).Limit(50).Find(&[]models.Notification{})
+	})
+	var lines []string
+	if err := db.Raw("EXPLAIN " + sql).Scan(&lines).Error; err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestListingQueriesUseIndexes(t *testing.T) {
+	db := testdb.Open(t)
+	seedNotifications(t, db)
+	user := uuid.MustParse("00000000-0000-0000-0000-000000000007")
+
+	cases := []struct {
+		name  string
+		query func(tx *gorm.DB) *gorm.DB
+		index string
+	}{
+		{
+			name: "list",
+			query: func(tx *gorm.DB) *gorm.DB {
+				return listNotificationsQuery(tx, user, false, false, true)
+			},
+			index: "idx_Notifications_responsibleId_timestamp",
+		},
+		{
+			name: "list including archived",
+			query: func(tx *gorm.DB) *gorm.DB {
+				return listNotificationsQuery(tx, user, false, true, false)
+			},
+			index: "idx_Notifications_responsibleId_timestamp",
+		},
+		{
+			name: "list unread",
+			query: func(tx *gorm.DB) *gorm.DB {
+				return listNotificationsQuery(tx, user, true, false, true)
+			},
+			index: "idx_Notifications_responsibleId_unread",
+		},
+		{
+			name: "pending on connect",
+			query: func(tx *gorm.DB) *gorm.DB {
+				return pendingNotificationsQuery(tx, user, time.Now().AddDate(0, 0, -30))
+			},
+			index: "idx_Notifications_responsibleId_unread",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			plan := explain(t, db, tc.query)
+			if !strings.Contains(plan, tc.index) {
+				t.Errorf("plan does not use %s:\n%s", tc.index, plan)
+			}
+			if strings.Contains(plan, `Seq Scan on "Notifications"`) {
+				t.Errorf("plan scans the whole table:\n%s", plan)
+			}
+		})
+	}
+}
+
+// BenchmarkListNotifications mide los listados sobre el mismo volumen que TestListingQueriesUseIndexes
+func BenchmarkListNotifications(b *testing.B) {
+	db := testdb.Open(b)
+	seedNotifications(b, db)
+	user := uuid.MustParse("00000000-0000-0000-0000-000000000007")
+
+	queries := map[string]func(tx *gorm.DB) *gorm.DB{
+		"grouped": func(tx *gorm.DB) *gorm.DB { return listNotificationsQuery(tx, user, false, false, true) },
+		"unread":  func(tx *gorm.DB) *gorm.DB { return listNotificationsQuery(tx, user, true, false, true) },
+		"pending": func(tx *gorm.DB) *gorm.DB {
+			return pendingNotificationsQuery(tx, user, time.Now().AddDate(0, 0, -30))
+		},
+	}
+	for name, query := range queries {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var notifications []models.Notification
+				if err := query(db).Limit(50).Find(&notifications).Error; err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"notifications/clock"
+	"notifications/config"
+	"notifications/lifecycle"
+	"notifications/models"
+	"notifications/outbox"
+	"notifications/preferences"
+	"notifications/push"
+	"notifications/scheduler"
+	"notifications/tracing"
+	"notifications/webhooks"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateNotification es el camino común de creación usado por el webhook y por gRPC:
+// aplica las preferencias del usuario, guarda la notificación, la agrupa si corresponde
+// y registra su entrega por canal en el outbox, que se publica tras el commit. Devuelve preferences.ErrSuppressed si el usuario no la quiere
+// y ErrRecipientRateLimited si superó su límite con la política drop.
+// La notificación guarda el trace ID de ctx y su entrega continúa esa traza.
+func CreateNotification(ctx context.Context, noti *models.Notification, clauses ...clause.Expression) error {
+	_, err := createNotification(ctx, noti, nil, clauses...)
+	return err
+}
+
+// CreateNotificationOnce es como CreateNotification pero idempotente por (scope, key).
+// Si la clave ya se usó y no ha expirado, carga la notificación original en noti,
+// no la vuelve a enviar y devuelve replayed=true.
+func CreateNotificationOnce(ctx context.Context, scope, key string, noti *models.Notification, clauses ...clause.Expression) (bool, error) {
+	if key == "" {
+		return false, CreateNotification(ctx, noti, clauses...)
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return false, ErrIdempotencyKeyTooLong
+	}
+
+	now := time.Now()
+	return createNotification(ctx, noti, &models.IdempotencyKey{
+		Scope:     scope,
+		Key:       key,
+		CreatedAt: now,
+		ExpiresAt: now.Add(idempotencyTTL()),
+	}, clauses...)
+}
+
+func createNotification(ctx context.Context, noti *models.Notification, idem *models.IdempotencyKey, clauses ...clause.Expression) (bool, error) {
+	if noti.Target == "" {
+		noti.Target = noti.RecipientID.String()
+	}
+	if noti.ID == uuid.Nil {
+		noti.ID = uuid.New()
+	}
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		noti.TraceID = &traceID
+	}
+	generatedID := noti.ID
+
+	var (
+		pref     models.NotificationPreference
+		replayed bool
+		merged   bool
+		held     bool
+		outboxed []uuid.UUID
+	)
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if idem != nil {
+			found, err := claimIdempotencyKey(tx, idem, noti)
+			if err != nil {
+				return err
+			}
+			if found {
+				replayed = true
+				return nil
+			}
+		}
+
+		// Preferencias y silencios del destinatario se consultan antes de guardar o entregar
+		p, err := preferences.Check(tx, *noti)
+		if err != nil {
+			return err
+		}
+		pref = p
+
+		// El RETURNING de GORM devuelve el id existente si ON CONFLICT actualizó otra fila
+		if err := tx.Clauses(clauses...).Create(noti).Error; err != nil {
+			return err
+		}
+		merged = noti.ID != generatedID
+
+		// Límite por destinatario: el exceso se descarta o se retiene para un resumen agregado. Una
+		// fusión no cuenta como notificación nueva, y el token va en tx para que un rollback no lo gaste.
+		overLimit := !merged && recipientOverLimit(ctx, tx, noti.ResponsibleID)
+		if overLimit && recipientPolicy() == RecipientPolicyDrop {
+			return ErrRecipientRateLimited
+		}
+
+		if merged {
+			if err := tx.First(noti, "id = ?", noti.ID).Error; err != nil {
+				return err
+			}
+			if err := lifecycle.Record(tx, lifecycle.Event(noti.ID, noti.ResponsibleID, models.NotificationEventMerged, "")); err != nil {
+				return err
+			}
+		} else {
+			if err := lifecycle.Record(tx, lifecycle.Event(noti.ID, noti.ResponsibleID, models.NotificationEventCreated, "")); err != nil {
+				return err
+			}
+
+			if _, err := aggregateNotification(tx, noti); err != nil {
+				return err
+			}
+
+			// Las suscripciones de webhook reciben cada notificación nueva, también en horario de silencio
+			if err := webhooks.Enqueue(tx, *noti, notificationPayload(*noti)); err != nil {
+				return err
+			}
+		}
+
+		// En horario de silencio se retiene para el resumen en vez de entregarse ahora
+		if now := Clock.Now(); pref.InQuietHours(now) {
+			deliverAt := pref.QuietHoursEndAfter(now)
+			if err := scheduler.Hold(tx, *noti, deliverAt); err != nil {
+				return err
+			}
+			if err := lifecycle.Record(tx, lifecycle.Event(noti.ID, noti.ResponsibleID, models.NotificationEventHeld,
+				"quiet hours until "+deliverAt.UTC().Format(time.RFC3339))); err != nil {
+				return err
+			}
+			held = true
+		} else if overLimit {
+			deliverAt := aggregateUntil(now)
+			if err := scheduler.Hold(tx, *noti, deliverAt); err != nil {
+				return err
+			}
+			if err := lifecycle.Record(tx, lifecycle.Event(noti.ID, noti.ResponsibleID, models.NotificationEventHeld,
+				"recipient rate limit until "+deliverAt.UTC().Format(time.RFC3339))); err != nil {
+				return err
+			}
+			held = true
+		} else {
+			// La entrega por canal se registra en el outbox dentro de la misma transacción
+			ids, err := outbox.Enqueue(tx, *noti, outboxChannels(pref))
+			if err != nil {
+				return err
+			}
+			outboxed = ids
+		}
+
+		if idem != nil {
+			return tx.Model(idem).Update("notificationId", noti.ID).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if replayed {
+		slog.InfoContext(ctx, "Idempotent replay, returning original notification", "scope", idem.Scope, "notification_id", noti.ID)
+		return true, nil
+	}
+
+	if merged {
+		slog.InfoContext(ctx, "Notification merged into existing row", "notification_id", noti.ID)
+	} else {
+		slog.InfoContext(ctx, "Notification saved", "notification_id", noti.ID, "type", noti.Type)
+	}
+	if held {
+		slog.InfoContext(ctx, "Notification held for digest", "notification_id", noti.ID, "user_id", noti.ResponsibleID)
+		return false, nil
+	}
+	// Sin la cancelación de la petición, que puede terminar antes, pero dentro de su traza
+	go flushOutbox(context.WithoutCancel(ctx), outboxed)
+	return false, nil
+}
+
+// Clock decide el horario de silencio y las ventanas de agregación al crear; los tests lo sustituyen
+// por un clock.Fake
+var Clock clock.Clock = clock.Real{}
+
+// Push es el canal push para usuarios sin WebSocket; nil si no hay proveedores configurados
+var Push *push.Service
+
+// Relay publica el outbox tras el commit; lo configura main
+var Relay *outbox.Relay
+
+// outboxChannels devuelve los canales por los que se debe entregar la notificación
+// según las preferencias del usuario y los canales disponibles en este servicio
+func outboxChannels(pref models.NotificationPreference) []string {
+	var channels []string
+	if pref.HasChannel(models.ChannelWebSocket) {
+		channels = append(channels, models.ChannelWebSocket)
+	}
+	if Push != nil && pref.HasChannel(models.ChannelPush) {
+		channels = append(channels, models.ChannelPush)
+	}
+	return channels
+}
+
+// flushOutbox publica de inmediato los eventos recién confirmados. Si falla o el proceso muere,
+// el relay los recoge en su siguiente ciclo.
+func flushOutbox(ctx context.Context, ids []uuid.UUID) {
+	if Relay == nil || len(ids) == 0 {
+		return
+	}
+	if _, err := Relay.Flush(ctx, ids); err != nil {
+		slog.WarnContext(ctx, "Could not flush outbox events", "error", err)
+	}
+}
+
+// notificationPayload construye el mensaje WebSocket de una notificación individual
+func notificationPayload(noti models.Notification) gin.H {
+	payload := gin.H{
+		"type":             "notification",
+		"id":               noti.ID,
+		"actorId":          noti.ActorID,
+		"recipientId":      noti.RecipientID,
+		"responsibleId":    noti.ResponsibleID,
+		"notificationType": noti.Type,
+		"target":           noti.Target,
+		"content":          noti.Content,
+		"timestamp":        noti.Timestamp.Format(time.RFC3339),
+		"read":             noti.Read,
+	}
+	if noti.GroupID != nil {
+		payload["groupId"] = noti.GroupID
+	}
+	return payload
+}
+
+// groupPayload construye el mensaje agregado; el frontend reemplaza la entrada con el mismo groupId
+func groupPayload(latest models.Notification, group models.NotificationGroup) gin.H {
+	payload := notificationPayload(latest)
+	payload["type"] = "notification_group"
+	payload["groupId"] = group.ID
+	payload["count"] = group.Count
+	payload["actorCount"] = group.ActorCount
+	payload["actors"] = group.ActorIDs.Strings()
+	payload["firstAt"] = group.FirstAt.Format(time.RFC3339)
+	return payload
+}
+
+func marshalMessage(payload gin.H) string {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Failed to marshal WebSocket message", "error", err)
+		return "{}"
+	}
+	return string(data)
+}
+
+// ReleaseDigest es el DigestSink: libera las notificaciones retenidas al outbox con los canales de las
+// preferencias de cada una, así que llegan por WebSocket (a la réplica que tenga la conexión) y por push
+// con los mismos reintentos que las demás. De un grupo solo se libera la más reciente, que lleva el
+// agregado. Email no pasa por aquí: su worker las recoge en cuanto dejan de estar retenidas.
+func ReleaseDigest(ctx context.Context, digest scheduler.Digest) error {
+	groups, err := loadGroups(digest.Notifications)
+	if err != nil {
+		return err
+	}
+
+	var ids []uuid.UUID
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, noti := range digest.Notifications {
+			if noti.GroupID != nil {
+				if group, ok := groups[*noti.GroupID]; ok && group.LatestNotificationID != noti.ID {
+					continue
+				}
+			}
+			pref, err := preferences.Resolve(tx, noti.ResponsibleID, noti.Type)
+			if err != nil {
+				return err
+			}
+			eventIDs, err := outbox.Enqueue(tx, noti, outboxChannels(pref))
+			if err != nil {
+				return err
+			}
+			ids = append(ids, eventIDs...)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	flushOutbox(ctx, ids)
+	return nil
+}
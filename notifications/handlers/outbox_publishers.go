@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"notifications/config"
+	"notifications/lifecycle"
+	"notifications/models"
+	"notifications/outbox"
+	"notifications/preferences"
+
+	"github.com/google/uuid"
+)
+
+// OutboxPublishers devuelve los publicadores del relay para los canales que entrega este servicio.
+// Email y webhooks tienen sus propias colas persistentes y no pasan por el outbox.
+func OutboxPublishers() map[string]outbox.Publisher {
+	publishers := map[string]outbox.Publisher{
+		models.ChannelWebSocket: outbox.PublisherFunc(publishWebSocket),
+	}
+	if Push != nil {
+		publishers[models.ChannelPush] = outbox.PublisherFunc(publishPush)
+	}
+	return publishers
+}
+
+// publishWebSocket envía la notificación (o el agregado de su grupo) por WebSocket. Si la conexión
+// del usuario está en otra réplica, el evento se encamina a ella para que lo publique y lo confirme.
+// Si no está conectado en ninguna se da por hecho: la recibirá como pendiente al conectarse.
+func publishWebSocket(ctx context.Context, noti models.Notification) error {
+	userId := noti.ResponsibleID.String()
+	if !isConnected(userId) {
+		replica, err := connectedReplica(ctx, noti.ResponsibleID)
+		if err != nil {
+			return err
+		}
+		if replica != "" && replica != ReplicaID {
+			return &outbox.RouteError{Replica: replica}
+		}
+	}
+
+	group, err := notificationGroup(noti)
+	if err != nil {
+		return err
+	}
+
+	message := marshalMessage(notificationPayload(noti))
+	if group != nil && group.Count > 1 {
+		message = marshalMessage(groupPayload(noti, *group))
+	}
+
+	if err := SendNotification(ctx, userId, message, noti); err != nil {
+		if errors.Is(err, ErrUserNotConnected) {
+			return nil
+		}
+		return err
+	}
+	slog.DebugContext(ctx, "WebSocket notification sent", "notification_id", noti.ID, "user_id", userId)
+	return nil
+}
+
+// publishPush envía la notificación a los dispositivos del usuario salvo que ya la haya
+// recibido por WebSocket
+func publishPush(ctx context.Context, noti models.Notification) error {
+	pref, err := preferences.Resolve(config.DB, noti.ResponsibleID, noti.Type)
+	if err != nil {
+		return err
+	}
+	if pref.HasChannel(models.ChannelWebSocket) && isConnectedAnywhere(ctx, noti.ResponsibleID) {
+		lifecycle.Delivery(config.DB, noti.ID, noti.ResponsibleID, models.ChannelPush, models.DeliveryResultSkipped, "user connected via websocket")
+		return nil
+	}
+
+	group, err := notificationGroup(noti)
+	if err != nil {
+		return err
+	}
+	return Push.Notify(ctx, noti, group)
+}
+
+// isConnectedAnywhere indica si el usuario tiene una conexión WebSocket en esta o en otra réplica
+func isConnectedAnywhere(ctx context.Context, userUUID uuid.UUID) bool {
+	if isConnected(userUUID.String()) {
+		return true
+	}
+	replica, err := connectedReplica(ctx, userUUID)
+	if err != nil {
+		slog.Warn("Could not load presence, assuming user is not connected", "user_id", userUUID, "error", err)
+	}
+	return replica != ""
+}
+
+// notificationGroup carga el grupo de la notificación; nil si no pertenece a ninguno
+func notificationGroup(noti models.Notification) (*models.NotificationGroup, error) {
+	groups, err := loadGroups([]models.Notification{noti})
+	if err != nil || noti.GroupID == nil {
+		return nil, err
+	}
+	if group, ok := groups[*noti.GroupID]; ok {
+		return &group, nil
+	}
+	return nil, nil
+}
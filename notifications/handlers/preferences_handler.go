@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"net/http"
+	"net/mail"
+	"notifications/config"
+	"notifications/dto"
+	"notifications/middleware"
+	"notifications/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetPreferences devuelve las preferencias por tipo y las reglas de silencio del usuario del token
+func GetPreferences(c *gin.Context) {
+	userUUID := middleware.UserID(c)
+
+	var prefs []models.NotificationPreference
+	if err := config.DB.Where(`"userId" = ?`, userUUID).Order("type").Find(&prefs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving preferences"})
+		return
+	}
+
+	var mutes []models.MuteRule
+	if err := config.DB.Where(`"userId" = ? AND ("expiresAt" IS NULL OR "expiresAt" > ?)`, userUUID, time.Now()).
+		Order(`"createdAt"`).Find(&mutes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving mute rules"})
+		return
+	}
+
+	var contact models.UserContact
+	if err := config.DB.Where(`"userId" = ?`, userUUID).Limit(1).Find(&contact).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving contact"})
+		return
+	}
+
+	c.JSON(http.StatusOK, preferencesResponse(prefs, mutes, contact.Email))
+}
+
+// UpdatePreferences reemplaza todas las preferencias y reglas de silencio del usuario del token
+func UpdatePreferences(c *gin.Context) {
+	userUUID := middleware.UserID(c)
+
+	var req dto.PreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Se guarda solo la dirección normalizada: "Ana <ana@example.com>" se queda en ana@example.com,
+	// que es lo que el worker usa como RCPT TO y en la cabecera To. "" borra el email.
+	var email *string
+	if req.Email != nil {
+		address := ""
+		if *req.Email != "" {
+			addr, err := mail.ParseAddress(*req.Email)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email"})
+				return
+			}
+			address = addr.Address
+		}
+		email = &address
+	}
+
+	now := time.Now()
+	prefs := make([]models.NotificationPreference, 0, len(req.Preferences))
+	seenTypes := map[string]bool{}
+	for _, p := range req.Preferences {
+		if seenTypes[p.Type] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "duplicated preference type: " + p.Type})
+			return
+		}
+		seenTypes[p.Type] = true
+
+		pref := models.NotificationPreference{
+			UserID:    userUUID,
+			Type:      p.Type,
+			Enabled:   *p.Enabled,
+			Channels:  models.StringList(p.Channels),
+			Timezone:  "UTC",
+			UpdatedAt: now,
+		}
+		if pref.Channels == nil {
+			pref.Channels = models.DefaultChannels()
+		}
+		if p.Timezone != "" {
+			if _, err := time.LoadLocation(p.Timezone); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timezone: " + p.Timezone})
+				return
+			}
+			pref.Timezone = p.Timezone
+		}
+		if p.QuietHours != nil {
+			if _, err := models.ParseClock(p.QuietHours.Start); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid quietHours.start: " + err.Error()})
+				return
+			}
+			if _, err := models.ParseClock(p.QuietHours.End); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid quietHours.end: " + err.Error()})
+				return
+			}
+			pref.QuietHoursStart = p.QuietHours.Start
+			pref.QuietHoursEnd = p.QuietHours.End
+		}
+		prefs = append(prefs, pref)
+	}
+
+	mutes := make([]models.MuteRule, 0, len(req.Mutes))
+	for _, m := range req.Mutes {
+		rule := models.MuteRule{ID: uuid.New(), UserID: userUUID, CreatedAt: now}
+		switch {
+		case m.ActorId != "" && m.Target != "":
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a mute rule must set either actorId or target, not both"})
+			return
+		case m.ActorId != "":
+			actorID, err := uuid.Parse(m.ActorId)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid actorId UUID in mute rule"})
+				return
+			}
+			// Se guarda en forma canónica (minúsculas, con guiones) para compararla con ActorID.String()
+			rule.Kind, rule.Value = models.MuteActor, actorID.String()
+		case m.Target != "":
+			rule.Kind, rule.Value = models.MuteTarget, m.Target
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a mute rule must set actorId or target"})
+			return
+		}
+		if m.ExpiresAt != "" {
+			expiresAt, err := time.Parse(time.RFC3339, m.ExpiresAt)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiresAt format, expected RFC3339"})
+				return
+			}
+			rule.ExpiresAt = &expiresAt
+		}
+		mutes = append(mutes, rule)
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(`"userId" = ?`, userUUID).Delete(&models.NotificationPreference{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where(`"userId" = ?`, userUUID).Delete(&models.MuteRule{}).Error; err != nil {
+			return err
+		}
+		if len(prefs) > 0 {
+			if err := tx.Create(&prefs).Error; err != nil {
+				return err
+			}
+		}
+		if len(mutes) > 0 {
+			if err := tx.Create(&mutes).Error; err != nil {
+				return err
+			}
+		}
+		if email != nil {
+			contact := models.UserContact{UserID: userUUID, Email: *email, UpdatedAt: now}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "userId"}},
+				DoUpdates: clause.AssignmentColumns([]string{"email", "updatedAt"}),
+			}).Create(&contact).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving preferences"})
+		return
+	}
+
+	var contact models.UserContact
+	if err := config.DB.Where(`"userId" = ?`, userUUID).Limit(1).Find(&contact).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving contact"})
+		return
+	}
+
+	c.JSON(http.StatusOK, preferencesResponse(prefs, mutes, contact.Email))
+}
+
+func preferencesResponse(prefs []models.NotificationPreference, mutes []models.MuteRule, email string) gin.H {
+	prefList := make([]gin.H, 0, len(prefs))
+	for _, p := range prefs {
+		entry := gin.H{
+			"type":       p.Type,
+			"enabled":    p.Enabled,
+			"channels":   p.Channels,
+			"timezone":   p.Timezone,
+			"quietHours": nil,
+		}
+		if p.QuietHoursStart != "" && p.QuietHoursEnd != "" {
+			entry["quietHours"] = gin.H{"start": p.QuietHoursStart, "end": p.QuietHoursEnd}
+		}
+		prefList = append(prefList, entry)
+	}
+
+	muteList := make([]gin.H, 0, len(mutes))
+	for _, m := range mutes {
+		entry := gin.H{"id": m.ID}
+		if m.Kind == models.MuteActor {
+			entry["actorId"] = m.Value
+		} else {
+			entry["target"] = m.Value
+		}
+		if m.ExpiresAt != nil {
+			entry["expiresAt"] = m.ExpiresAt.Format(time.RFC3339)
+		}
+		muteList = append(muteList, entry)
+	}
+
+	return gin.H{
+		"email":       email,
+		"preferences": prefList,
+		"mutes":       muteList,
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"notifications/clock"
+	"notifications/config"
+	"notifications/internal/testdb"
+	"notifications/models"
+	"notifications/scheduler"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// useTestDB apunta config.DB y Clock a la base de pruebas y al reloj dados durante el test
+func useTestDB(t *testing.T, db *gorm.DB, c clock.Clock) {
+	t.Helper()
+	prevDB, prevClock := config.DB, Clock
+	config.DB, Clock = db, c
+	t.Cleanup(func() { config.DB, Clock = prevDB, prevClock })
+}
+
+func TestQuietHoursHoldAndRelease(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	user := uuid.New()
+
+	// 22:00-07:00 en Nueva York la noche del cambio a EDT (10 de marzo de 2024): la ventana
+	// termina a las 07:00 EDT = 11:00Z, una hora antes de lo que daría sumar horas en EST
+	if err := db.Create(&models.NotificationPreference{
+		UserID:          user,
+		Type:            models.DefaultPreferenceType,
+		Enabled:         true,
+		Channels:        models.StringList{models.ChannelWebSocket},
+		QuietHoursStart: "22:00",
+		QuietHoursEnd:   "07:00",
+		Timezone:        "America/New_York",
+		UpdatedAt:       time.Now(),
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	windowEnd := time.Date(2024, 3, 10, 11, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(time.Date(2024, 3, 10, 4, 0, 0, 0, time.UTC)) // 23:00 EST
+	useTestDB(t, db, fake)
+
+	create := func() models.Notification {
+		t.Helper()
+		noti := models.Notification{
+			ActorID:       uuid.New(),
+			RecipientID:   uuid.New(),
+			ResponsibleID: user,
+			Type:          "like",
+			Content:       "quiet",
+			Timestamp:     fake.Now(),
+		}
+		if err := CreateNotification(ctx, &noti); err != nil {
+			t.Fatalf("CreateNotification: %v", err)
+		}
+		return noti
+	}
+	outboxEvents := func(noti models.Notification) int64 {
+		t.Helper()
+		var count int64
+		db.Model(&models.OutboxEvent{}).Where(`"notificationId" = ?`, noti.ID).Count(&count)
+		return count
+	}
+
+	// Dentro del horario de silencio se retiene hasta el fin de la ventana y no se entrega
+	held := create()
+	var item models.DigestItem
+	if err := db.Where(`"notificationId" = ?`, held.ID).First(&item).Error; err != nil {
+		t.Fatalf("notification was not held: %v", err)
+	}
+	if !item.DeliverAt.Equal(windowEnd) {
+		t.Errorf("deliverAt = %s, want %s", item.DeliverAt.UTC().Format(time.RFC3339), windowEnd.Format(time.RFC3339))
+	}
+	if n := outboxEvents(held); n != 0 {
+		t.Errorf("held notification has %d outbox events, want 0", n)
+	}
+	var pending []models.Notification
+	if err := pendingNotificationsQuery(db, user, fake.Now().AddDate(0, 0, -30)).Find(&pending).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("held notification is sent as pending on connect")
+	}
+
+	// El scheduler la libera exactamente al terminar la ventana
+	var released []scheduler.Digest
+	digests := &scheduler.Scheduler{
+		DB:        db,
+		Clock:     fake,
+		Interval:  time.Minute,
+		BatchSize: 500,
+		Sink: scheduler.SinkFunc(func(ctx context.Context, digest scheduler.Digest) error {
+			released = append(released, digest)
+			return ReleaseDigest(ctx, digest)
+		}),
+	}
+	fake.Set(windowEnd.Add(-time.Second))
+	if _, err := digests.RunOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(released) != 0 {
+		t.Fatal("digest released before the window ended")
+	}
+	fake.Set(windowEnd)
+	if _, err := digests.RunOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(released) != 1 || len(released[0].Notifications) != 1 || released[0].Notifications[0].ID != held.ID {
+		t.Fatalf("released = %+v, want one digest with the held notification", released)
+	}
+	if n := outboxEvents(held); n != 1 {
+		t.Errorf("released notification has %d outbox events, want 1 (websocket)", n)
+	}
+
+	// Fuera del horario de silencio se entrega por el outbox sin retener
+	fake.Set(windowEnd.Add(time.Hour))
+	delivered := create()
+	if err := db.Where(`"notificationId" = ?`, delivered.ID).First(&models.DigestItem{}).Error; err == nil {
+		t.Error("notification outside quiet hours was held")
+	}
+	if n := outboxEvents(delivered); n != 1 {
+		t.Errorf("notification outside quiet hours has %d outbox events, want 1", n)
+	}
+}
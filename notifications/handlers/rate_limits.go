@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"notifications/config"
+	"notifications/ratelimit"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RateLimits guarda los buckets de los límites de ingesta y por destinatario; nil los desactiva.
+// Lo configura main según RATE_LIMIT_STORE.
+var RateLimits ratelimit.Store
+
+// ErrRecipientRateLimited indica que el destinatario superó su límite y la política es descartar
+var ErrRecipientRateLimited = errors.New("recipient rate limit exceeded")
+
+// Políticas para las notificaciones que superan el límite por destinatario
+const (
+	RecipientPolicyAggregate = "aggregate"
+	RecipientPolicyDrop      = "drop"
+)
+
+// IngestLimit es el límite por productor (fuente del webhook, servicio gRPC o IP):
+// RATE_LIMIT_INGEST_PER_MINUTE peticiones por minuto con ráfagas de RATE_LIMIT_INGEST_BURST
+func IngestLimit() ratelimit.Limit {
+	return ratelimit.PerMinute(config.GetIntEnv("RATE_LIMIT_INGEST_PER_MINUTE", 600), config.GetIntEnv("RATE_LIMIT_INGEST_BURST", 100))
+}
+
+// recipientLimit es el máximo de notificaciones nuevas por destinatario
+func recipientLimit() ratelimit.Limit {
+	return ratelimit.PerMinute(config.GetIntEnv("RATE_LIMIT_RECIPIENT_PER_MINUTE", 30), config.GetIntEnv("RATE_LIMIT_RECIPIENT_BURST", 60))
+}
+
+// recipientPolicy decide qué se hace con el exceso: aggregate lo retiene para un resumen, drop lo rechaza
+func recipientPolicy() string {
+	if config.GetEnv("RATE_LIMIT_RECIPIENT_POLICY", RecipientPolicyAggregate) == RecipientPolicyDrop {
+		return RecipientPolicyDrop
+	}
+	return RecipientPolicyAggregate
+}
+
+// recipientAggregateWindow agrupa el exceso en ventanas fijas para que llegue en un solo resumen
+func recipientAggregateWindow() time.Duration {
+	return config.GetDurationEnv("RATE_LIMIT_RECIPIENT_AGGREGATE_WINDOW", 5*time.Minute)
+}
+
+// recipientOverLimit consume un token del bucket del destinatario y devuelve true si no quedaba. Con un
+// almacén en Postgres el token se consume con tx, así que una creación revertida no lo gasta.
+// Si el almacén falla se deja pasar la notificación: perder el límite es mejor que perder notificaciones.
+func recipientOverLimit(ctx context.Context, tx *gorm.DB, userID uuid.UUID) bool {
+	limit := recipientLimit()
+	if RateLimits == nil || !limit.Enabled() {
+		return false
+	}
+
+	key := "recipient:" + userID.String()
+	var (
+		decision ratelimit.Decision
+		err      error
+	)
+	if store, ok := RateLimits.(ratelimit.TxStore); ok {
+		// En un SAVEPOINT: si la sentencia falla, la transacción de creación sigue siendo válida
+		err = tx.Transaction(func(sp *gorm.DB) error {
+			decision, err = store.TakeTx(sp, key, limit)
+			return err
+		})
+	} else {
+		decision, err = RateLimits.Take(ctx, key, limit)
+	}
+	if err != nil {
+		slog.Warn("Rate limit store unavailable, skipping recipient limit", "error", err)
+		return false
+	}
+	return !decision.Allowed
+}
+
+// aggregateUntil es el final de la ventana de agregación en curso
+func aggregateUntil(now time.Time) time.Time {
+	window := recipientAggregateWindow()
+	return now.Truncate(window).Add(window)
+}
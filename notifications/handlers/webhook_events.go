@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"notifications/dto"
+	"notifications/metrics"
+	"notifications/middleware"
+	"notifications/models"
+	"notifications/preferences"
+	"notifications/utils"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// WebhookEventHandler valida el data de un evento y construye la notificación a guardar
+type WebhookEventHandler func(data json.RawMessage) (models.Notification, error)
+
+var webhookEvents = map[string]WebhookEventHandler{}
+
+// RegisterWebhookEvent registra el handler de un tipo de evento para POST /webhook/:source
+func RegisterWebhookEvent(event string, handler WebhookEventHandler) {
+	webhookEvents[event] = handler
+}
+
+func init() {
+	RegisterWebhookEvent("like.created", likeCreated)
+	RegisterWebhookEvent("comment.created", commentCreated)
+	RegisterWebhookEvent("follow.created", followCreated)
+	RegisterWebhookEvent("mention.created", mentionCreated)
+}
+
+// Etiquetas de event en /metrics para peticiones sin un evento registrado; el nombre recibido
+// no se usa como etiqueta para no crear series arbitrarias
+const (
+	webhookEventUnknown     = "unknown"
+	webhookEventUnsupported = "unsupported"
+)
+
+// countWebhookEvent registra en /metrics el resultado de un evento recibido
+func countWebhookEvent(event, outcome string) {
+	metrics.WebhookEvents.WithLabelValues(event, outcome).Inc()
+}
+
+// errInvalidWebhookData envuelve los errores de validación del data de un evento
+var errInvalidWebhookData = errors.New("invalid event data")
+
+// Webhook recibe eventos de otros servicios en POST /webhook/:source y los despacha
+// según el campo event al handler registrado
+func Webhook(c *gin.Context) {
+	source := c.Param("source")
+	if !middleware.ValidWebhookSource(source) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown webhook source"})
+		return
+	}
+
+	var req dto.WebhookEnvelope
+	if err := c.ShouldBindJSON(&req); err != nil {
+		countWebhookEvent(webhookEventUnknown, "invalid")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	handler, ok := webhookEvents[req.Event]
+	if !ok {
+		countWebhookEvent(webhookEventUnsupported, "unsupported")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":     fmt.Sprintf("unsupported event %q", req.Event),
+			"supported": supportedWebhookEvents(),
+		})
+		return
+	}
+
+	notification, err := handler(req.Data)
+	if err != nil {
+		countWebhookEvent(req.Event, "invalid")
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "event": req.Event})
+		return
+	}
+
+	slog.InfoContext(c.Request.Context(), "Webhook event received", "event", req.Event, "source", source)
+	storeWebhookNotification(c, "webhook:"+source, req.Event, &notification)
+}
+
+// storeWebhookNotification guarda la notificación de un webhook y escribe la respuesta HTTP.
+// Las notificaciones con dedupeKey actualizan la fila activa existente en vez de duplicarse.
+// event solo se usa para contar el resultado en /metrics.
+func storeWebhookNotification(c *gin.Context, scope, event string, notification *models.Notification) {
+	var clauses []clause.Expression
+	if notification.DedupeKey != nil {
+		clauses = append(clauses, DedupeConflict())
+	}
+
+	replayed, err := CreateNotificationOnce(c.Request.Context(), scope, c.GetHeader(IdempotencyKeyHeader), notification, clauses...)
+	if errors.Is(err, ErrIdempotencyKeyTooLong) {
+		countWebhookEvent(event, "invalid")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key too long"})
+		return
+	}
+	if errors.Is(err, preferences.ErrSuppressed) {
+		countWebhookEvent(event, "suppressed")
+		c.JSON(http.StatusOK, gin.H{"message": "Notification suppressed by user preferences"})
+		return
+	}
+	if errors.Is(err, ErrRecipientRateLimited) {
+		countWebhookEvent(event, "rate_limited")
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "recipient rate limit exceeded"})
+		return
+	}
+	if err != nil {
+		countWebhookEvent(event, "error")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving notification"})
+		return
+	}
+	if replayed {
+		countWebhookEvent(event, "replayed")
+		c.Header("Idempotent-Replayed", "true")
+	} else {
+		countWebhookEvent(event, "created")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Notification created successfully!",
+		"id":      notification.ID,
+	})
+}
+
+func supportedWebhookEvents() []string {
+	events := make([]string, 0, len(webhookEvents))
+	for event := range webhookEvents {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+	return events
+}
+
+// decodeWebhookData deserializa y valida el data de un evento con las reglas binding del DTO
+func decodeWebhookData(data json.RawMessage, dst interface{}) error {
+	if err := json.Unmarshal(data, dst); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidWebhookData, err)
+	}
+	if err := binding.Validator.ValidateStruct(dst); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidWebhookData, err)
+	}
+	return nil
+}
+
+// parseWebhookTimestamp usa el parser compartido con gRPC (RFC3339, ISO de Python o epoch) en UTC
+func parseWebhookTimestamp(value string) (time.Time, error) {
+	parsed, err := utils.ParseTimestamp(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", errInvalidWebhookData, err)
+	}
+	return parsed, nil
+}
+
+func likeCreated(raw json.RawMessage) (models.Notification, error) {
+	var data dto.LikeCreatedData
+	if err := decodeWebhookData(raw, &data); err != nil {
+		return models.Notification{}, err
+	}
+	timestamp, err := parseWebhookTimestamp(data.Timestamp)
+	if err != nil {
+		return models.Notification{}, err
+	}
+
+	return models.Notification{
+		ActorID:       uuid.MustParse(data.ActorId),
+		RecipientID:   uuid.MustParse(data.RecipientId),
+		ResponsibleID: uuid.MustParse(data.ResponsibleId),
+		Type:          "like",
+		Target:        data.TargetId,
+		Content:       data.Content,
+		Timestamp:     timestamp,
+	}, nil
+}
+
+func commentCreated(raw json.RawMessage) (models.Notification, error) {
+	var data dto.CommentCreatedData
+	if err := decodeWebhookData(raw, &data); err != nil {
+		return models.Notification{}, err
+	}
+	timestamp, err := parseWebhookTimestamp(data.Timestamp)
+	if err != nil {
+		return models.Notification{}, err
+	}
+
+	// Un reenvío del mismo comentario no genera una segunda notificación
+	return models.Notification{
+		ActorID:       uuid.MustParse(data.ActorId),
+		RecipientID:   uuid.MustParse(data.RecipientId),
+		ResponsibleID: uuid.MustParse(data.ResponsibleId),
+		Type:          "comment",
+		Target:        data.TargetId,
+		Content:       data.Content,
+		Timestamp:     timestamp,
+		DedupeKey:     DedupeKey("comment", data.CommentId, data.ResponsibleId),
+	}, nil
+}
+
+func followCreated(raw json.RawMessage) (models.Notification, error) {
+	var data dto.FollowCreatedData
+	if err := decodeWebhookData(raw, &data); err != nil {
+		return models.Notification{}, err
+	}
+	timestamp, err := parseWebhookTimestamp(data.Timestamp)
+	if err != nil {
+		return models.Notification{}, err
+	}
+
+	// Misma clave que FollowCreated por gRPC: un follow repetido actualiza la fila existente
+	noti := models.Notification{
+		ActorID:       uuid.MustParse(data.ActorId),
+		RecipientID:   uuid.MustParse(data.RecipientId),
+		ResponsibleID: uuid.MustParse(data.RecipientId),
+		Type:          "follow",
+		Content:       data.Content,
+		Timestamp:     timestamp,
+	}
+	noti.DedupeKey = DedupeKey(noti.ActorID.String(), noti.RecipientID.String(), noti.Type, noti.Content)
+	return noti, nil
+}
+
+func mentionCreated(raw json.RawMessage) (models.Notification, error) {
+	var data dto.MentionCreatedData
+	if err := decodeWebhookData(raw, &data); err != nil {
+		return models.Notification{}, err
+	}
+	timestamp, err := parseWebhookTimestamp(data.Timestamp)
+	if err != nil {
+		return models.Notification{}, err
+	}
+
+	return models.Notification{
+		ActorID:       uuid.MustParse(data.ActorId),
+		RecipientID:   uuid.MustParse(data.RecipientId),
+		ResponsibleID: uuid.MustParse(data.RecipientId),
+		Type:          "mention",
+		Target:        data.TargetId,
+		Content:       data.Content,
+		Timestamp:     timestamp,
+		DedupeKey:     DedupeKey("mention", data.ActorId, data.RecipientId, data.TargetId),
+	}, nil
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"notifications/config"
+	"notifications/dto"
+	"notifications/middleware"
+	"notifications/models"
+	"notifications/webhooks"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// WebhookAllowInsecureTargets permite URLs http y destinos internos (localhost, red privada).
+// Solo para desarrollo: en producción expone la red interna a cualquier usuario.
+func WebhookAllowInsecureTargets() bool {
+	return config.GetBoolEnv("WEBHOOK_ALLOW_INSECURE_TARGETS", false)
+}
+
+// CreateWebhookSubscription registra un endpoint que recibirá las notificaciones del usuario.
+// Si no se envía secreto se genera uno; solo se devuelve en esta respuesta.
+func CreateWebhookSubscription(c *gin.Context) {
+	userUUID := middleware.UserID(c)
+
+	var req dto.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Solo destinos https públicos: el dispatcher vuelve a comprobar la IP al conectar
+	if err := webhooks.ValidateURL(c.Request.Context(), req.URL, WebhookAllowInsecureTargets()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating secret"})
+			return
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	sub := models.WebhookSubscription{
+		ID:         uuid.New(),
+		UserID:     userUUID,
+		URL:        req.URL,
+		EventTypes: models.StringList(req.EventTypes),
+		Secret:     secret,
+		Active:     true,
+		CreatedAt:  time.Now(),
+	}
+	if sub.EventTypes == nil {
+		sub.EventTypes = models.StringList{}
+	}
+	if err := config.DB.Create(&sub).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating webhook subscription"})
+		return
+	}
+
+	response := webhookSubscriptionResponse(sub)
+	response["secret"] = sub.Secret
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetWebhookSubscriptions lista las suscripciones del usuario (sin secretos)
+func GetWebhookSubscriptions(c *gin.Context) {
+	userUUID := middleware.UserID(c)
+
+	var subs []models.WebhookSubscription
+	if err := config.DB.Where(`"userId" = ?`, userUUID).Order(`"createdAt" DESC`).Find(&subs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching webhook subscriptions"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(subs))
+	for _, sub := range subs {
+		result = append(result, webhookSubscriptionResponse(sub))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// DeleteWebhookSubscription elimina una suscripción y su registro de entregas
+func DeleteWebhookSubscription(c *gin.Context) {
+	userUUID := middleware.UserID(c)
+
+	result := config.DB.Where(`id = ? AND "userId" = ?`, c.Param("id"), userUUID).Delete(&models.WebhookSubscription{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error removing webhook subscription"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook subscription not found or unauthorized"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook subscription removed"})
+}
+
+// GetWebhookDeliveries devuelve el registro de entregas de una suscripción.
+// Acepta ?status=pending|succeeded|dead_letter y ?limit= (máximo 200).
+func GetWebhookDeliveries(c *gin.Context) {
+	sub, ok := ownedWebhookSubscription(c)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	query := config.DB.Where(`"subscriptionId" = ?`, sub.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order(`"createdAt" DESC`).Limit(limit).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching webhook deliveries"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(deliveries))
+	for _, d := range deliveries {
+		result = append(result, gin.H{
+			"id":             d.ID,
+			"notificationId": d.NotificationID,
+			"event":          d.Event,
+			"status":         d.Status,
+			"attempts":       d.Attempts,
+			"nextAttemptAt":  d.NextAttemptAt,
+			"lastStatusCode": d.LastStatusCode,
+			"lastError":      d.LastError,
+			"createdAt":      d.CreatedAt,
+			"deliveredAt":    d.DeliveredAt,
+		})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// RetryWebhookDelivery vuelve a poner en cola una entrega en dead_letter
+func RetryWebhookDelivery(c *gin.Context) {
+	sub, ok := ownedWebhookSubscription(c)
+	if !ok {
+		return
+	}
+
+	result := config.DB.Model(&models.WebhookDelivery{}).
+		Where(`id = ? AND "subscriptionId" = ? AND status = ?`, c.Param("deliveryId"), sub.ID, models.WebhookStatusDeadLetter).
+		Updates(map[string]interface{}{
+			"status":        models.WebhookStatusPending,
+			"attempts":      0,
+			"nextAttemptAt": time.Now(),
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrying webhook delivery"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dead-lettered delivery not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook delivery re-queued"})
+}
+
+// ownedWebhookSubscription carga la suscripción de :id si pertenece al usuario del token
+func ownedWebhookSubscription(c *gin.Context) (models.WebhookSubscription, bool) {
+	var sub models.WebhookSubscription
+
+	userUUID := middleware.UserID(c)
+
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook id"})
+		return sub, false
+	}
+
+	if err := config.DB.Where(`id = ? AND "userId" = ?`, c.Param("id"), userUUID).First(&sub).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook subscription not found or unauthorized"})
+		return sub, false
+	}
+	return sub, true
+}
+
+func webhookSubscriptionResponse(sub models.WebhookSubscription) gin.H {
+	return gin.H{
+		"id":         sub.ID,
+		"url":        sub.URL,
+		"eventTypes": sub.EventTypes,
+		"active":     sub.Active,
+		"createdAt":  sub.CreatedAt,
+	}
+}
@@ -1,26 +1,39 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"notifications/config"
+	"notifications/dto"
+	"notifications/lifecycle"
+	"notifications/metrics"
+	"notifications/middleware"
 	"notifications/models"
-	"notifications/utils"
-	"strings"
+	"notifications/ratelimit"
+	"notifications/scheduler"
+	"notifications/tracing"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		// En desarrollo, permite cualquier origen
 		// En producción, deberías verificar el origen específico
-		origin := r.Header.Get("Origin")
-		log.Println("WebSocket connection attempt from origin:", origin)
+		slog.DebugContext(r.Context(), "WebSocket connection attempt", "origin", r.Header.Get("Origin"))
 		return true // Cambia esto en producción para verificar orígenes específicos
 	},
 	// Buffer sizes opcionales para mejorar rendimiento
@@ -28,104 +41,233 @@ var upgrader = websocket.Upgrader{
 	WriteBufferSize: 1024,
 }
 
-var Connections = make(map[string]*websocket.Conn)
+// wsConn serializa las escrituras: gorilla/websocket no admite escritores concurrentes
+// y ahora escriben el handler, la creación de notificaciones y el scheduler de resúmenes
+type wsConn struct {
+	*websocket.Conn
+	writeMu sync.Mutex
+}
 
-func WsHandler(c *gin.Context) {
-	// Validación de autenticación ANTES del upgrade
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		log.Println("Missing Authorization header")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing authorization header"})
-		return
-	}
+func (c *wsConn) WriteMessage(messageType int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.Conn.WriteMessage(messageType, data)
+}
 
-	tokenParts := strings.Split(authHeader, " ")
-	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-		log.Println("Invalid Authorization header format:", authHeader)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
-		return
-	}
+var Connections = make(map[string]*wsConn)
+var connectionsMu sync.RWMutex
 
-	tokenStr := tokenParts[1]
-	log.Println("Token received for WebSocket connection")
+// ReplicaID identifica a este proceso en "UserPresence" y en los eventos del outbox encaminados a él;
+// cambia en cada arranque, así que la presencia de un proceso anterior nunca se toma como propia
+var ReplicaID = uuid.NewString()
 
-	claims, err := utils.ParseJWT(tokenStr)
-	if err != nil {
-		log.Println("Token parse error:", err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-		return
+// Motivos de rechazo de mensajes del cliente, usados en logs y métricas
+const (
+	wsViolationRateLimited = "rate_limited"
+	wsViolationTooLarge    = "too_large"
+)
+
+// Texto del aviso al cliente y motivo del cierre 1008 por cada tipo de rechazo
+var (
+	wsViolationWarnings = map[string]string{
+		wsViolationRateLimited: "Too many messages, slow down",
+		wsViolationTooLarge:    "Message too large",
+	}
+	wsViolationCloseReasons = map[string]string{
+		wsViolationRateLimited: "message rate exceeded",
+		wsViolationTooLarge:    "message too large",
 	}
+)
 
-	userId, ok := claims["userId"].(string)
-	if !ok {
-		log.Println("Invalid token payload: missing or wrong userId type")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token payload"})
-		return
+// wsUnsupportedMessage es la respuesta a un mensaje del cliente que no es de un tipo conocido
+var wsUnsupportedMessage = marshalMessage(gin.H{"type": "error", "code": "unsupported_message", "message": "Unsupported message type"})
+
+// wsInboundPolicy limita lo que un cliente puede enviar por su conexión
+type wsInboundPolicy struct {
+	// MaxMessageSize es el tamaño máximo de un mensaje; uno mayor se descarta con aviso
+	MaxMessageSize int64
+	// ReadLimit es el tope duro de lectura: un mensaje mayor no llega a leerse y gorilla cierra la
+	// conexión con 1009. Queda por encima de MaxMessageSize para que se aplique antes el aviso.
+	ReadLimit int64
+	// Limit es el token bucket de mensajes por conexión
+	Limit ratelimit.Limit
+	// MaxViolations son los mensajes rechazados (por tamaño o ritmo) que se avisan antes de cerrar (1008)
+	MaxViolations int
+}
+
+// wsReadLimitFactor fija el tope duro por defecto en varias veces MaxMessageSize
+const wsReadLimitFactor = 4
+
+func inboundPolicy() wsInboundPolicy {
+	maxSize := int64(config.GetIntEnv("WS_MAX_MESSAGE_SIZE", 4096))
+	return wsInboundPolicy{
+		MaxMessageSize: maxSize,
+		ReadLimit:      int64(config.GetIntEnv("WS_READ_LIMIT", int(maxSize*wsReadLimitFactor))),
+		Limit: ratelimit.Limit{
+			Rate:  float64(config.GetIntEnv("WS_MESSAGES_PER_SECOND", 5)),
+			Burst: config.GetIntEnv("WS_MESSAGE_BURST", 10),
+		},
+		MaxViolations: config.GetIntEnv("WS_MAX_VIOLATIONS", 3),
 	}
+}
+
+// ErrUserNotConnected indica que el usuario no tiene una conexión WebSocket activa
+var ErrUserNotConnected = errors.New("user not connected")
 
-	log.Println("Token valid for user:", userId, "- Proceeding with WebSocket upgrade")
+func WsHandler(c *gin.Context) {
+	// La autenticación la hace el middleware antes del upgrade
+	userId := middleware.UserID(c).String()
 
 	// Solo hacer upgrade después de validar autenticación
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	rawConn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Println("WebSocket upgrade error:", err)
+		slog.WarnContext(c.Request.Context(), "WebSocket upgrade error", "error", err)
 		return
 	}
+	conn := &wsConn{Conn: rawConn}
+
+	policy := inboundPolicy()
+	if policy.ReadLimit > 0 {
+		rawConn.SetReadLimit(policy.ReadLimit)
+	}
 
 	// Cerrar conexión existente si el usuario ya está conectado
+	connectionsMu.Lock()
 	if existingConn, exists := Connections[userId]; exists {
-		log.Println("Closing existing connection for user:", userId)
+		slog.Info("Closing existing WebSocket connection", "user_id", userId)
 		existingConn.Close()
 	}
-
 	Connections[userId] = conn
-	log.Printf("New WebSocket connection established for user: %s", userId)
-	log.Printf("Total active connections: %d", len(Connections))
-	log.Printf("Connected users: %v", getConnectedUsersList())
+	total := len(Connections)
+	metrics.WSActiveConnections.Set(float64(total))
+	connectionsMu.Unlock()
+
+	updatePresence(userId, true)
+
+	slog.InfoContext(c.Request.Context(), "WebSocket connection established", "user_id", userId, "active_connections", total)
 
 	defer func() {
 		conn.Close()
-		delete(Connections, userId)
-		log.Println("Connection closed for user:", userId)
+		// Solo se elimina si no fue reemplazada por una conexión más nueva del mismo usuario
+		connectionsMu.Lock()
+		replaced := Connections[userId] != conn
+		if !replaced {
+			delete(Connections, userId)
+			metrics.WSActiveConnections.Set(float64(len(Connections)))
+		}
+		connectionsMu.Unlock()
+		if !replaced {
+			updatePresence(userId, false)
+		}
+		slog.Info("WebSocket connection closed", "user_id", userId)
 	}()
 
 	// Enviar mensaje de confirmación
 	welcomeMsg := fmt.Sprintf(`{"type":"welcome","message":"Connected successfully","userId":"%s"}`, userId)
 	if err := conn.WriteMessage(websocket.TextMessage, []byte(welcomeMsg)); err != nil {
-		log.Println("Failed to send welcome message:", err)
+		slog.Warn("Failed to send welcome message", "user_id", userId, "error", err)
 		return
 	}
 
 	// Enviar notificaciones pendientes
-	go sendPendingNotifications(userId, conn)
+	go sendPendingNotifications(c.Request.Context(), userId, conn)
 
-	// Loop de lectura de mensajes
+	// Loop de lectura de mensajes: un mensaje demasiado grande o sin token se descarta con un aviso al
+	// cliente y, tras MaxViolations avisos, se cierra la conexión con 1008
+	bucket := ratelimit.NewBucket(policy.Limit, time.Now())
+	violations := 0
 	for {
 		_, msg, err := conn.ReadMessage()
 		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Println("WebSocket unexpected close error:", err)
+			if errors.Is(err, websocket.ErrReadLimit) {
+				// Tope duro: gorilla ya envió el cierre 1009 (message too big)
+				metrics.WSInboundViolations.WithLabelValues(wsViolationTooLarge).Inc()
+				metrics.WSPolicyCloses.WithLabelValues(wsViolationTooLarge).Inc()
+				slog.Warn("WebSocket message exceeds read limit, closing connection", "user_id", userId, "read_limit", policy.ReadLimit)
+			} else if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				slog.Warn("WebSocket unexpected close error", "user_id", userId, "error", err)
 			} else {
-				log.Println("WebSocket connection closed normally for user:", userId)
+				slog.Debug("WebSocket connection closed by client", "user_id", userId)
 			}
 			break
 		}
-		log.Println("Message received from user", userId, ":", string(msg))
+		slog.Debug("WebSocket message received", "user_id", userId, "size", len(msg))
+
+		reason := ""
+		if policy.MaxMessageSize > 0 && int64(len(msg)) > policy.MaxMessageSize {
+			reason = wsViolationTooLarge
+		} else {
+			metrics.WSInboundMessages.Inc()
+			metrics.WSInboundMessageBytes.Observe(float64(len(msg)))
+			if policy.Limit.Enabled() && !bucket.Take(time.Now()).Allowed {
+				reason = wsViolationRateLimited
+			}
+		}
+		if reason != "" {
+			violations++
+			metrics.WSInboundViolations.WithLabelValues(reason).Inc()
+			if violations > policy.MaxViolations {
+				metrics.WSPolicyCloses.WithLabelValues(reason).Inc()
+				slog.Warn("WebSocket inbound policy exceeded, closing connection", "user_id", userId, "reason", reason, "violations", violations)
+				closeWithPolicyViolation(conn, wsViolationCloseReasons[reason])
+				break
+			}
+			slog.Info("WebSocket message dropped", "user_id", userId, "reason", reason, "size", len(msg), "violations", violations)
+			warning := marshalMessage(gin.H{
+				"type":        "error",
+				"code":        reason,
+				"message":     wsViolationWarnings[reason],
+				"violations":  violations,
+				"maxWarnings": policy.MaxViolations,
+			})
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(warning)); err != nil {
+				break
+			}
+			continue
+		}
 
-		// Echo del mensaje como confirmación
-		response := fmt.Sprintf(`{"type":"echo","message":"Message received","original":"%s"}`, string(msg))
-		if err := conn.WriteMessage(websocket.TextMessage, []byte(response)); err != nil {
-			log.Println("Failed to send echo response:", err)
+		// Confirmación de lectura en pantalla: {"type":"seen","ids":[...]}
+		var clientMsg dto.WsClientMessage
+		if json.Unmarshal(msg, &clientMsg) == nil && clientMsg.Type == dto.WsMessageSeen {
+			count := markSeen(userId, clientMsg.IDs)
+			ack := marshalMessage(gin.H{"type": "seen_ack", "count": count})
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(ack)); err != nil {
+				slog.Warn("Failed to send seen acknowledgement", "user_id", userId, "error", err)
+				break
+			}
+			continue
+		}
+
+		// Cualquier otro mensaje recibe una respuesta de tamaño fijo, sin reenviar lo que mandó el cliente
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(wsUnsupportedMessage)); err != nil {
+			slog.Warn("Failed to reply to unsupported message", "user_id", userId, "error", err)
 			break
 		}
 	}
 }
 
+// closeWithPolicyViolation envía el cierre 1008 (policy violation) antes de cerrar el socket
+func closeWithPolicyViolation(conn *wsConn, reason string) {
+	message := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+	if err := conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second)); err != nil {
+		slog.Debug("Could not send WebSocket close frame", "error", err)
+	}
+}
+
+// pendingNotificationsQuery selecciona las no leídas, no archivadas y no retenidas desde since; usa el
+// índice parcial "idx_Notifications_responsibleId_unread"
+func pendingNotificationsQuery(db *gorm.DB, userUUID uuid.UUID, since time.Time) *gorm.DB {
+	return db.Where(`"responsibleId" = ? AND read = ? AND archived = ? AND timestamp >= ?`,
+		userUUID, false, false, since).
+		Scopes(latestOfGroupScope(true, false, true), scheduler.HeldScope).
+		Order("timestamp DESC")
+}
+
 // sendPendingNotifications envía las notificaciones no leídas al usuario cuando se conecta
-func sendPendingNotifications(userId string, conn *websocket.Conn) {
+func sendPendingNotifications(ctx context.Context, userId string, conn *wsConn) {
 	userUUID, err := uuid.Parse(userId)
 	if err != nil {
-		log.Printf("Invalid userId format for pending notifications: %s", userId)
+		slog.Warn("Invalid userId format for pending notifications", "user_id", userId)
 		return
 	}
 
@@ -133,74 +275,218 @@ func sendPendingNotifications(userId string, conn *websocket.Conn) {
 	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
 	var notifications []models.Notification
 
-	if err := config.DB.Where(`"responsibleId" = ? AND read = ? AND timestamp >= ?`,
-		userUUID, false, thirtyDaysAgo).
-		Order("timestamp DESC").
+	if err := pendingNotificationsQuery(config.DB.WithContext(ctx), userUUID, thirtyDaysAgo).
 		Limit(50). // Limitar a 50 notificaciones para evitar sobrecarga
 		Find(&notifications).Error; err != nil {
-		log.Printf("Error fetching pending notifications for user %s: %v", userId, err)
+		slog.Error("Error fetching pending notifications", "user_id", userId, "error", err)
 		return
 	}
 
+	metrics.WSPendingBackfillSize.Observe(float64(len(notifications)))
 	if len(notifications) == 0 {
-		log.Printf("No pending notifications for user %s", userId)
+		slog.Debug("No pending notifications", "user_id", userId)
+		return
+	}
+
+	groups, err := loadGroups(notifications)
+	if err != nil {
+		slog.Error("Error fetching notification groups", "user_id", userId, "error", err)
 		return
 	}
 
-	log.Printf("Sending %d pending notifications to user %s", len(notifications), userId)
+	slog.Info("Sending pending notifications", "user_id", userId, "count", len(notifications))
 
-	// Enviar cada notificación
+	// Enviar cada notificación (o el agregado de su grupo)
 	for _, notification := range notifications {
-		notificationMessage := fmt.Sprintf(`{
-			"type": "notification",
-			"id": "%s",
-			"actorId": "%s",
-			"recipientId": "%s",
-			"responsibleId": "%s",
-			"notificationType": "%s",
-			"content": "%s",
-			"timestamp": "%s",
-			"read": false,
-			"pending": true
-		}`, notification.ID, notification.ActorID, notification.RecipientID, notification.ResponsibleID,
-			notification.Type, notification.Content, notification.Timestamp.Format(time.RFC3339))
-
-		if err := conn.WriteMessage(websocket.TextMessage, []byte(notificationMessage)); err != nil {
-			log.Printf("Failed to send pending notification %s to user %s: %v",
-				notification.ID, userId, err)
+		payload := notificationPayload(notification)
+		if notification.GroupID != nil {
+			if group, ok := groups[*notification.GroupID]; ok && group.Count > 1 {
+				payload = groupPayload(notification, group)
+			}
+		}
+		payload["pending"] = true
+
+		_, span := startDeliverySpan(ctx, userId, notification)
+		err := conn.WriteMessage(websocket.TextMessage, []byte(marshalMessage(payload)))
+		endDeliverySpan(span, err)
+		countOutbound(err)
+		recordWebSocketDelivery(userUUID, []uuid.UUID{notification.ID}, err, "pending on connect")
+		if err != nil {
+			slog.Warn("Failed to send pending notification", "notification_id", notification.ID, "user_id", userId, "error", err)
 			// Si falla el envío de una notificación, paramos para no saturar el log
 			break
 		}
 	}
 
-	log.Printf("Finished sending pending notifications to user %s", userId)
 }
 
-func SendNotification(userId string, message string) error {
-	log.Printf("SendNotification called for userId: %s", userId)
-	log.Printf("Current active connections: %v", getConnectedUsersList())
+// SendNotification escribe el mensaje en el WebSocket del usuario dentro de un span de entrega.
+// Las notificaciones incluidas en el mensaje reciben en su historial el intento de entrega con su resultado.
+func SendNotification(ctx context.Context, userId string, message string, notifications ...models.Notification) error {
+	_, span := startDeliverySpan(ctx, userId, notifications...)
+	err := writeToUser(userId, message)
+	endDeliverySpan(span, err)
+
+	if len(notifications) > 0 {
+		if userUUID, parseErr := uuid.Parse(userId); parseErr == nil {
+			ids := make([]uuid.UUID, 0, len(notifications))
+			for _, noti := range notifications {
+				ids = append(ids, noti.ID)
+			}
+			recordWebSocketDelivery(userUUID, ids, err, "")
+		}
+	}
+	return err
+}
 
+// startDeliverySpan abre el span de una entrega por WebSocket. Si la entrega ocurre fuera de la traza
+// que creó la notificación (relay, resumen, reconexión), el atributo notification.trace_ids la enlaza
+// con su origen.
+func startDeliverySpan(ctx context.Context, userId string, notifications ...models.Notification) (context.Context, trace.Span) {
+	ids := make([]string, 0, len(notifications))
+	var traceIDs []string
+	for _, noti := range notifications {
+		ids = append(ids, noti.ID.String())
+		if noti.TraceID != nil && !slices.Contains(traceIDs, *noti.TraceID) {
+			traceIDs = append(traceIDs, *noti.TraceID)
+		}
+	}
+	return tracing.Start(ctx, "websocket.deliver",
+		attribute.String("user.id", userId),
+		attribute.StringSlice("notification.ids", ids),
+		attribute.StringSlice("notification.trace_ids", traceIDs),
+	)
+}
+
+// endDeliverySpan cierra el span con el resultado; un usuario no conectado no es un error
+func endDeliverySpan(span trace.Span, err error) {
+	span.SetAttributes(attribute.String("websocket.result", outboundResult(err)))
+	if errors.Is(err, ErrUserNotConnected) {
+		err = nil
+	}
+	tracing.End(span, err)
+}
+
+func writeToUser(userId string, message string) error {
+	connectionsMu.RLock()
 	conn, ok := Connections[userId]
+	connectionsMu.RUnlock()
 	if !ok {
-		log.Printf("User %s is not connected. Notification will be stored for later delivery.", userId)
-		return fmt.Errorf("user not connected")
+		slog.Debug("User not connected, notification stays pending", "user_id", userId)
+		countOutbound(ErrUserNotConnected)
+		return ErrUserNotConnected
 	}
 
 	err := conn.WriteMessage(websocket.TextMessage, []byte(message))
+	countOutbound(err)
 	if err != nil {
-		log.Printf("Failed to send notification to user %s: %v", userId, err)
+		slog.Warn("Failed to send WebSocket notification", "user_id", userId, "error", err)
 		return err
 	}
 
-	log.Printf("Notification sent successfully to user %s", userId)
+	slog.Debug("WebSocket message sent", "user_id", userId)
 	return nil
 }
 
-// Función auxiliar para debug - obtener lista de usuarios conectados
-func getConnectedUsersList() []string {
-	var users []string
-	for userId := range Connections {
-		users = append(users, userId)
+// countOutbound registra en /metrics el resultado de un mensaje enviado a un cliente
+func countOutbound(err error) {
+	metrics.WSOutboundMessages.WithLabelValues(outboundResult(err)).Inc()
+}
+
+// outboundResult clasifica el resultado de un envío: sent, failed o not_connected
+func outboundResult(err error) string {
+	switch {
+	case errors.Is(err, ErrUserNotConnected):
+		return "not_connected"
+	case err != nil:
+		return "failed"
+	}
+	return "sent"
+}
+
+// isConnected indica si el usuario tiene una conexión WebSocket activa en esta réplica
+func isConnected(userId string) bool {
+	connectionsMu.RLock()
+	defer connectionsMu.RUnlock()
+	_, ok := Connections[userId]
+	return ok
+}
+
+// recordWebSocketDelivery registra el intento de entrega por WebSocket de cada notificación
+func recordWebSocketDelivery(userUUID uuid.UUID, notificationIDs []uuid.UUID, err error, detail string) {
+	result := lifecycle.Result(err)
+	if errors.Is(err, ErrUserNotConnected) {
+		result = models.DeliveryResultNotConnected
+	} else if err != nil {
+		detail = err.Error()
+	}
+	for _, id := range notificationIDs {
+		lifecycle.Delivery(config.DB, id, userUUID, models.ChannelWebSocket, result, detail)
+	}
+}
+
+// maxSeenIDs limita cuántas notificaciones se pueden confirmar en un solo mensaje
+const maxSeenIDs = 100
+
+// markSeen registra el evento seen de las notificaciones del usuario que el cliente confirma haber
+// mostrado; ignora los ids que no son suyos. Devuelve cuántas se registraron.
+func markSeen(userId string, ids []uuid.UUID) int {
+	userUUID, err := uuid.Parse(userId)
+	if err != nil || len(ids) == 0 {
+		return 0
+	}
+	if len(ids) > maxSeenIDs {
+		ids = ids[:maxSeenIDs]
+	}
+
+	var owned []uuid.UUID
+	if err := config.DB.Model(&models.Notification{}).
+		Where(`id IN ? AND "responsibleId" = ?`, ids, userUUID).
+		Pluck("id", &owned).Error; err != nil {
+		slog.Error("Could not load seen notifications", "user_id", userId, "error", err)
+		return 0
+	}
+	if err := lifecycle.RecordMany(config.DB, owned, userUUID, models.NotificationEventSeen, ""); err != nil {
+		slog.Error("Could not record seen notifications", "user_id", userId, "error", err)
+		return 0
+	}
+	return len(owned)
+}
+
+// updatePresence guarda en Postgres si el usuario está conectado y en qué réplica; el worker de email
+// lo usa para saber cuánto tiempo lleva desconectado y el outbox para encaminar las entregas
+func updatePresence(userId string, connected bool) {
+	userUUID, err := uuid.Parse(userId)
+	if err != nil {
+		return
+	}
+
+	if !connected {
+		// Solo la réplica dueña marca la desconexión: si el usuario ya se reconectó en otra, se respeta
+		err = config.DB.Model(&models.UserPresence{}).
+			Where(`"userId" = ? AND "replicaId" = ?`, userUUID, ReplicaID).
+			Updates(map[string]interface{}{"connected": false, "lastSeenAt": time.Now()}).Error
+	} else {
+		presence := models.UserPresence{UserID: userUUID, Connected: true, LastSeenAt: time.Now(), ReplicaID: &ReplicaID}
+		err = config.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "userId"}},
+			DoUpdates: clause.AssignmentColumns([]string{"connected", "lastSeenAt", "replicaId"}),
+		}).Create(&presence).Error
+	}
+	if err != nil {
+		slog.Error("Could not update presence", "user_id", userId, "error", err)
+	}
+}
+
+// connectedReplica devuelve la réplica que tiene la conexión del usuario según "UserPresence";
+// vacío si no está conectado en ninguna
+func connectedReplica(ctx context.Context, userUUID uuid.UUID) (string, error) {
+	var presence models.UserPresence
+	err := config.DB.WithContext(ctx).
+		Where(`"userId" = ? AND connected = ?`, userUUID, true).
+		Limit(1).Find(&presence).Error
+	if err != nil || presence.ReplicaID == nil {
+		return "", err
 	}
-	return users
+	return *presence.ReplicaID, nil
 }
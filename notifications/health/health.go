@@ -0,0 +1,73 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Estados de una comprobación y del conjunto
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check comprueba una dependencia y devuelve el detalle que se muestra en la respuesta.
+// Debe respetar el deadline de ctx; un error marca la comprobación como fallida.
+type Check func(ctx context.Context) (map[string]interface{}, error)
+
+// Result es el resultado de una comprobación en /livez, /readyz y el log
+type Result struct {
+	Status    string                 `json:"status"`
+	LatencyMs float64                `json:"latencyMs"`
+	Error     string                 `json:"error,omitempty"`
+	Detail    map[string]interface{} `json:"detail,omitempty"`
+}
+
+// Run ejecuta las comprobaciones en paralelo, cada una con el timeout dado, y devuelve
+// si todas pasaron junto con el resultado de cada una
+func Run(ctx context.Context, checks map[string]Check, timeout time.Duration) (bool, map[string]Result) {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]Result, len(checks))
+		healthy = true
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := run(ctx, check, timeout)
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[name] = result
+			if result.Status != StatusOK {
+				healthy = false
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return healthy, results
+}
+
+func run(ctx context.Context, check Check, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	detail, err := check(ctx)
+	result := Result{
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Detail:    detail,
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// minStaleAfter evita falsos fallos en workers con intervalos muy cortos
+const minStaleAfter = 30 * time.Second
+
+// Heartbeat registra cada vuelta del bucle de un worker. El worker se considera parado si no late
+// en tres intervalos; un error en la vuelta no lo marca como parado (la dependencia tiene su propia
+// comprobación) pero se muestra en el detalle.
+type Heartbeat struct {
+	name     string
+	interval time.Duration
+
+	mu      sync.Mutex
+	last    time.Time
+	lastErr error
+}
+
+var (
+	heartbeatsMu sync.Mutex
+	heartbeats   = map[string]*Heartbeat{}
+)
+
+// NewHeartbeat registra el latido de un worker que da una vuelta cada interval.
+// Hasta el primer latido cuenta desde el registro.
+func NewHeartbeat(name string, interval time.Duration) *Heartbeat {
+	h := &Heartbeat{name: name, interval: interval, last: time.Now()}
+
+	heartbeatsMu.Lock()
+	heartbeats[name] = h
+	heartbeatsMu.Unlock()
+	return h
+}
+
+// Beat marca una vuelta del bucle con su resultado. Es seguro sobre un Heartbeat nil, así los
+// workers creados sin latido (p.ej. en herramientas) no necesitan comprobarlo.
+func (h *Heartbeat) Beat(err error) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	h.last = time.Now()
+	h.lastErr = err
+	h.mu.Unlock()
+}
+
+func (h *Heartbeat) staleAfter() time.Duration {
+	return max(3*h.interval, minStaleAfter)
+}
+
+// Workers es la comprobación de los latidos de todos los workers registrados
+func Workers(ctx context.Context) (map[string]interface{}, error) {
+	heartbeatsMu.Lock()
+	registered := make([]*Heartbeat, 0, len(heartbeats))
+	for _, h := range heartbeats {
+		registered = append(registered, h)
+	}
+	heartbeatsMu.Unlock()
+
+	now := time.Now()
+	detail := make(map[string]interface{}, len(registered))
+	var stale []string
+	for _, h := range registered {
+		h.mu.Lock()
+		age := now.Sub(h.last)
+		worker := map[string]interface{}{
+			"lastBeatSecondsAgo": age.Round(time.Millisecond).Seconds(),
+			"intervalSeconds":    h.interval.Seconds(),
+		}
+		if h.lastErr != nil {
+			worker["lastError"] = h.lastErr.Error()
+		}
+		h.mu.Unlock()
+
+		if age > h.staleAfter() {
+			worker["stale"] = true
+			stale = append(stale, h.name)
+		}
+		detail[h.name] = worker
+	}
+
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		return detail, fmt.Errorf("workers without heartbeat: %v", stale)
+	}
+	return detail, nil
+}
@@ -0,0 +1,20 @@
+package health
+
+import (
+	"context"
+	"runtime"
+	"time"
+)
+
+// startedAt es el arranque del proceso, para el detalle de /livez
+var startedAt = time.Now()
+
+// Process es la comprobación de /livez: si el proceso puede responder está vivo. No depende de la
+// base de datos ni de los workers, que pueden fallar por causas que un reinicio no arregla y se
+// comprueban en /readyz; el detalle solo informa.
+func Process(ctx context.Context) (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"goroutines":    runtime.NumGoroutine(),
+		"uptimeSeconds": time.Since(startedAt).Round(time.Second).Seconds(),
+	}, nil
+}
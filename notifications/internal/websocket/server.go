@@ -1,7 +1,7 @@
 package websocket
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -19,27 +19,27 @@ var upgrader = websocket.Upgrader{
 func HandleWebSocket(c *gin.Context) {
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Println("Upgrade error:", err)
+		slog.Warn("WebSocket upgrade error", "error", err)
 		return
 	}
 	defer conn.Close()
 
-	log.Println("WebSocket connection established")
+	slog.Debug("WebSocket connection established")
 
 	for {
 		messageType, message, err := conn.ReadMessage()
 		if err != nil {
-			log.Println("Read error:", err)
+			slog.Debug("WebSocket read error", "error", err)
 			break
 		}
 
-		log.Printf("Received message: %s\n", message)
+		slog.Debug("WebSocket message received", "size", len(message))
 
 		// Responder pong de prueba
 		response := []byte("pong: " + string(message))
 		err = conn.WriteMessage(messageType, response)
 		if err != nil {
-			log.Println("Write error:", err)
+			slog.Warn("WebSocket write error", "error", err)
 			break
 		}
 	}
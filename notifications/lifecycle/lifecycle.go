@@ -0,0 +1,74 @@
+package lifecycle
+
+import (
+	"log/slog"
+	"notifications/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Event crea un evento del historial de la notificación sin canal ni resultado
+func Event(notificationID, userID uuid.UUID, event, detail string) models.NotificationEvent {
+	return models.NotificationEvent{
+		NotificationID: notificationID,
+		UserID:         userID,
+		Event:          event,
+		Detail:         detail,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// Record añade eventos al historial. Dentro de una transacción hace que el evento y el cambio
+// que describe se confirmen juntos.
+func Record(db *gorm.DB, events ...models.NotificationEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return db.Create(&events).Error
+}
+
+// RecordMany registra el mismo evento para varias notificaciones (por ejemplo, todo un grupo)
+func RecordMany(db *gorm.DB, ids []uuid.UUID, userID uuid.UUID, event, detail string) error {
+	events := make([]models.NotificationEvent, 0, len(ids))
+	for _, id := range ids {
+		events = append(events, Event(id, userID, event, detail))
+	}
+	return Record(db, events...)
+}
+
+// Delivery registra un intento de entrega por un canal con su resultado y, si tuvo éxito,
+// el evento delivered. Es best-effort: un fallo al escribir el historial no afecta a la entrega.
+func Delivery(db *gorm.DB, notificationID, userID uuid.UUID, channel, result, detail string) {
+	attempt := Event(notificationID, userID, models.NotificationEventDeliveryAttempted, detail)
+	attempt.Channel = channel
+	attempt.Result = result
+
+	events := []models.NotificationEvent{attempt}
+	if result == models.DeliveryResultOK {
+		delivered := Event(notificationID, userID, models.NotificationEventDelivered, "")
+		delivered.Channel = channel
+		events = append(events, delivered)
+	}
+
+	if err := Record(db, events...); err != nil {
+		slog.Error("Could not record notification lifecycle event", "notification_id", notificationID, "channel", channel, "error", err)
+	}
+}
+
+// Result traduce el error de un intento de entrega al resultado registrado
+func Result(err error) string {
+	if err != nil {
+		return models.DeliveryResultFailed
+	}
+	return models.DeliveryResultOK
+}
+
+// ErrorDetail devuelve el texto del error para el campo detail, o "" si no hay error
+func ErrorDetail(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// Setup configura el logger por defecto (slog.Default y el paquete log estándar) a partir de:
+//
+//	LOG_LEVEL=debug|info|warn|error    (info)
+//	LOG_FORMAT=json|text               (json)
+//	LOG_USER_CONTENT=true              no redacta el contenido de usuario (solo para desarrollo)
+//
+// Se lee con os.Getenv y no con config para que config pueda usar el logger.
+func Setup() {
+	slog.SetDefault(New(os.Stdout, os.Getenv("LOG_FORMAT"), os.Getenv("LOG_LEVEL"), os.Getenv("LOG_USER_CONTENT") == "true"))
+}
+
+// New crea un logger con redacción y request IDs sobre el formato indicado
+func New(w io.Writer, format, level string, logUserContent bool) *slog.Logger {
+	options := &slog.HandlerOptions{
+		Level:       parseLevel(level),
+		ReplaceAttr: redactor{userContent: logUserContent}.replace,
+	}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, "text") {
+		handler = slog.NewTextHandler(w, options)
+	} else {
+		handler = slog.NewJSONHandler(w, options)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+type requestIDKey struct{}
+
+// WithRequestID guarda el request ID en el contexto; los logs con ese contexto lo incluyen
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID devuelve el request ID del contexto, o "" si no tiene
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler añade request_id y trace_id a cada registro emitido con un contexto que los tenga
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// Fatal registra el error y termina el proceso, como log.Fatal
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// Gorm devuelve un logger de GORM que escribe en slog y nunca incluye los valores de las consultas
+func Gorm() gormlogger.Interface {
+	return gormlogger.New(gormWriter{}, gormlogger.Config{
+		SlowThreshold:             500 * time.Millisecond,
+		LogLevel:                  gormlogger.Warn,
+		IgnoreRecordNotFoundError: true,
+		ParameterizedQueries:      true,
+	})
+}
+
+type gormWriter struct{}
+
+func (gormWriter) Printf(format string, args ...interface{}) {
+	slog.Warn(strings.TrimSpace(fmt.Sprintf(format, args...)), "component", "gorm")
+}
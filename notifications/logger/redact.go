@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// Claves cuyo valor nunca se escribe: credenciales y secretos
+var secretKeys = map[string]bool{
+	"authorization": true, "token": true, "accesstoken": true, "refreshtoken": true, "jwt": true,
+	"secret": true, "password": true, "apikey": true, "signature": true, "cookie": true,
+	"privatekey": true, "credentials": true, "claims": true,
+}
+
+// Claves con contenido escrito por usuarios; se redactan salvo con LOG_USER_CONTENT=true
+var userContentKeys = map[string]bool{
+	"content": true, "body": true, "payload": true, "email": true, "original": true,
+}
+
+var (
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)\S+`)
+)
+
+type redactor struct {
+	userContent bool
+}
+
+// replace se aplica a cada atributo (incluido el mensaje): redacta por nombre de clave y
+// además borra de cualquier texto lo que parezca un JWT o un header Bearer
+func (r redactor) replace(groups []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(attr.Key))
+	if secretKeys[key] || (!r.userContent && userContentKeys[key]) {
+		return slog.String(attr.Key, redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, scrub(attr.Value.String()))
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, scrub(err.Error()))
+		}
+		if s, ok := attr.Value.Any().(fmt.Stringer); ok {
+			return slog.String(attr.Key, scrub(s.String()))
+		}
+	}
+	return attr
+}
+
+// scrub elimina tokens de un texto libre (mensajes de error de terceros, URLs, ...)
+func scrub(s string) string {
+	if !strings.Contains(s, "eyJ") && !strings.Contains(strings.ToLower(s), "bearer") {
+		return s
+	}
+	s = jwtPattern.ReplaceAllString(s, "[REDACTED_JWT]")
+	return bearerPattern.ReplaceAllString(s, "${1}"+redacted)
+}
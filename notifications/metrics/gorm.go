@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+// DBQueryDuration mide la latencia de las sentencias de GORM por operación y tabla
+var DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Subsystem: "db",
+	Name:      "query_duration_seconds",
+	Help:      "Database statement latency by GORM operation and table.",
+	Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+}, []string{"operation", "table"})
+
+const startKey = "metrics:start"
+
+// GormPlugin registra callbacks de GORM que miden cada sentencia. Se activa con db.Use(metrics.GormPlugin{}).
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "metrics"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("metrics:before_create", startTimer),
+		callbacks.Create().After("gorm:create").Register("metrics:after_create", observe("create")),
+		callbacks.Query().Before("gorm:query").Register("metrics:before_query", startTimer),
+		callbacks.Query().After("gorm:query").Register("metrics:after_query", observe("query")),
+		callbacks.Update().Before("gorm:update").Register("metrics:before_update", startTimer),
+		callbacks.Update().After("gorm:update").Register("metrics:after_update", observe("update")),
+		callbacks.Delete().Before("gorm:delete").Register("metrics:before_delete", startTimer),
+		callbacks.Delete().After("gorm:delete").Register("metrics:after_delete", observe("delete")),
+		callbacks.Row().Before("gorm:row").Register("metrics:before_row", startTimer),
+		callbacks.Row().After("gorm:row").Register("metrics:after_row", observe("row")),
+		callbacks.Raw().Before("gorm:raw").Register("metrics:before_raw", startTimer),
+		callbacks.Raw().After("gorm:raw").Register("metrics:after_raw", observe("raw")),
+	)
+}
+
+func startTimer(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+func observe(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(startKey)
+		if !ok {
+			return
+		}
+		start, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		DBQueryDuration.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+	}
+}
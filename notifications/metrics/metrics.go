@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace es el prefijo de todas las métricas del servicio
+const namespace = "notifications"
+
+// Handler sirve las métricas en formato Prometheus. Si token no está vacío exige
+// "Authorization: Bearer <token>" para no exponerlas a cualquiera que alcance el puerto HTTP.
+func Handler(token string) http.Handler {
+	handler := promhttp.Handler()
+	if token == "" {
+		return handler
+	}
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Peticiones HTTP y llamadas gRPC
+var (
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method, route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	GRPCRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "grpc",
+		Name:      "request_duration_seconds",
+		Help:      "gRPC call latency by full method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+)
+
+// Entrega por WebSocket
+var (
+	WSActiveConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "active_connections",
+		Help:      "WebSocket connections currently open on this instance.",
+	})
+
+	// WSOutboundMessages cuenta los mensajes enviados a clientes por result: sent, failed o not_connected
+	WSOutboundMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "outbound_messages_total",
+		Help:      "Messages written to WebSocket clients, by result.",
+	}, []string{"result"})
+
+	WSPendingBackfillSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "pending_backfill_size",
+		Help:      "Pending notifications sent to a client when it connects.",
+		Buckets:   []float64{0, 1, 5, 10, 25, 50},
+	})
+)
+
+// Ingesta de webhooks
+var (
+	// WebhookEvents cuenta los eventos recibidos por event y outcome (created, replayed, suppressed,
+	// rate_limited, invalid, unsupported o error)
+	WebhookEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "events_total",
+		Help:      "Inbound webhook events by event type and outcome.",
+	}, []string{"event", "outcome"})
+)
+
+// queueDepthTimeout acota cada consulta de profundidad para que un scrape no se quede colgado de la base de datos
+const queueDepthTimeout = 2 * time.Second
+
+// queueDepthDesc describe el gauge queue_depth, común a todas las colas
+var queueDepthDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "queue_depth"),
+	"Items waiting in a persistent work queue.", []string{"queue"}, nil)
+
+// queueDepth consulta la profundidad de una cola en cada scrape
+type queueDepth struct {
+	queue string
+	depth func(ctx context.Context) (int64, error)
+}
+
+func (q *queueDepth) Describe(ch chan<- *prometheus.Desc) { ch <- queueDepthDesc }
+
+// Collect no emite muestra si la consulta falla: un 0 haría pensar que la cola está vacía
+func (q *queueDepth) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), queueDepthTimeout)
+	defer cancel()
+	n, err := q.depth(ctx)
+	if err != nil {
+		slog.Warn("Queue depth query failed", "queue", q.queue, "error", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(n), q.queue)
+}
+
+// QueueDepth expone el número de elementos pendientes de una cola (outbox, webhooks, email); depth se
+// consulta en cada scrape con un timeout corto
+func QueueDepth(queue string, depth func(ctx context.Context) (int64, error)) {
+	prometheus.MustRegister(&queueDepth{queue: queue, depth: depth})
+}
+
+// Mensajes que los clientes envían por WebSocket y aplicación de sus límites
+var (
+	WSInboundMessages = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "inbound_messages_total",
+		Help:      "Messages received from WebSocket clients that passed the size limit.",
+	})
+
+	WSInboundMessageBytes = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "inbound_message_bytes",
+		Help:      "Size of messages received from WebSocket clients.",
+		Buckets:   prometheus.ExponentialBuckets(16, 4, 7),
+	})
+
+	// WSInboundViolations cuenta los mensajes rechazados por reason: rate_limited o too_large
+	WSInboundViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "inbound_violations_total",
+		Help:      "Inbound WebSocket messages rejected by the per-connection limits, by reason.",
+	}, []string{"reason"})
+
+	// WSPolicyCloses cuenta las conexiones cerradas por el servidor por reason: rate_limited o too_large
+	WSPolicyCloses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "policy_closes_total",
+		Help:      "WebSocket connections closed by the server after limit violations, by reason.",
+	}, []string{"reason"})
+)
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Claims son los datos ya validados del JWT de usuario
+type Claims struct {
+	UserID    uuid.UUID
+	Roles     []string
+	ExpiresAt *time.Time // nil si el token no tiene exp
+}
+
+// HasRole indica si el token incluye alguno de los roles dados
+func (c *Claims) HasRole(roles ...string) bool {
+	for _, have := range c.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tokenClaims es la forma del payload que emite el servicio de usuarios
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	UserID string   `json:"userId"`
+	Roles  []string `json:"roles"`
+	Role   string   `json:"role"`
+}
+
+// Errores de autenticación; su texto es el que recibe el cliente en el 401
+var (
+	ErrMissingAuthorization = errors.New("missing authorization header")
+	ErrInvalidAuthorization = errors.New("invalid authorization header format")
+	ErrInvalidToken         = errors.New("invalid token")
+	ErrTokenExpired         = errors.New("token expired")
+	ErrInvalidTokenPayload  = errors.New("invalid token payload")
+)
+
+const claimsKey = "auth.claims"
+
+// ParseToken valida la firma, la vigencia, el emisor y la audiencia de un JWT de usuario
+// y devuelve sus claims tipados
+func ParseToken(tokenString string) (*Claims, error) {
+	var raw tokenClaims
+	err := jwtVerifier().Parse(tokenString, &raw)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, ErrTokenExpired
+	}
+	if err != nil {
+		slog.Info("Rejected user token", "error", err)
+		return nil, ErrInvalidToken
+	}
+
+	userID, err := uuid.Parse(raw.UserID)
+	if err != nil {
+		return nil, ErrInvalidTokenPayload
+	}
+
+	claims := &Claims{UserID: userID, Roles: raw.Roles}
+	if raw.Role != "" {
+		claims.Roles = append(claims.Roles, raw.Role)
+	}
+	if raw.ExpiresAt != nil {
+		expiresAt := raw.ExpiresAt.Time
+		claims.ExpiresAt = &expiresAt
+	}
+	return claims, nil
+}
+
+// bearerToken extrae el token del header Authorization
+func bearerToken(c *gin.Context) (string, error) {
+	header := c.GetHeader("Authorization")
+	if header == "" {
+		return "", ErrMissingAuthorization
+	}
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if !found || token == "" || strings.Contains(token, " ") {
+		return "", ErrInvalidAuthorization
+	}
+	return token, nil
+}
+
+// RequireAuth exige un JWT de usuario válido y guarda sus claims en el contexto.
+// Cualquier fallo responde 401 con {"error": "..."} y corta la cadena.
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := bearerToken(c)
+		if err == nil {
+			var claims *Claims
+			claims, err = ParseToken(token)
+			if err == nil {
+				c.Set(claimsKey, claims)
+				c.Next()
+				return
+			}
+		}
+
+		c.Header("WWW-Authenticate", `Bearer realm="notifications"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	}
+}
+
+// RequireRole exige que el usuario autenticado tenga alguno de los roles; si no, 403.
+// Debe ir después de RequireAuth.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims == nil || !claims.HasRole(roles...) {
+			Forbidden(c, "insufficient role")
+			return
+		}
+		c.Next()
+	}
+}
+
+// Forbidden responde 403 con el mismo formato que el resto de errores de autorización
+func Forbidden(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": message})
+}
+
+// GetClaims devuelve los claims que dejó RequireAuth; nil si la ruta no está protegida
+func GetClaims(c *gin.Context) *Claims {
+	if value, ok := c.Get(claimsKey); ok {
+		if claims, ok := value.(*Claims); ok {
+			return claims
+		}
+	}
+	return nil
+}
+
+// UserID devuelve el usuario autenticado. Solo se debe usar en rutas protegidas por RequireAuth.
+func UserID(c *gin.Context) uuid.UUID {
+	if claims := GetClaims(c); claims != nil {
+		return claims.UserID
+	}
+	return uuid.Nil
+}
@@ -0,0 +1,225 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrUnknownKey indica que ninguna clave del JWKS corresponde al kid del token
+var ErrUnknownKey = errors.New("no verification key for kid")
+
+// JWKS es el conjunto de claves públicas del servicio de identidad, leído de una URL o de un
+// fichero. Las claves se cachean durante TTL; un kid desconocido fuerza una recarga (como mucho
+// una cada MinRefresh) para aceptar claves nuevas en cuanto se publican. La descarga se hace sin el
+// mutex y una sola vez para todas las peticiones concurrentes: mientras dura, las claves cacheadas
+// se siguen sirviendo y solo esperan las peticiones con un kid desconocido.
+type JWKS struct {
+	URL        string
+	File       string
+	TTL        time.Duration
+	MinRefresh time.Duration
+	Client     *http.Client
+
+	refreshes singleflight.Group
+
+	mu          sync.Mutex
+	keys        map[string]interface{}
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+// Key devuelve la clave pública con el kid dado. Sin kid solo se acepta si el JWKS tiene una única clave.
+func (j *JWKS) Key(kid string) (interface{}, error) {
+	j.mu.Lock()
+	key, found := j.lookup(kid)
+	stale := time.Since(j.fetchedAt) > j.TTL
+	due := time.Since(j.lastAttempt) >= j.MinRefresh
+	j.mu.Unlock()
+
+	switch {
+	case !found:
+		// Un kid desconocido espera a la descarga en curso, o la lanza si ha pasado MinRefresh
+		j.refresh()
+		j.mu.Lock()
+		key, found = j.lookup(kid)
+		j.mu.Unlock()
+	case stale && due:
+		// Clave conocida pero caducada: se sirve la cacheada y se recarga en segundo plano
+		go j.refresh()
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+	return key, nil
+}
+
+// refresh descarga el JWKS fuera del mutex, como mucho una vez cada MinRefresh; las llamadas
+// concurrentes esperan a la misma descarga. Con un error se siguen usando las claves anteriores.
+func (j *JWKS) refresh() {
+	j.refreshes.Do("jwks", func() (interface{}, error) {
+		j.mu.Lock()
+		if time.Since(j.lastAttempt) < j.MinRefresh {
+			j.mu.Unlock()
+			return nil, nil
+		}
+		j.lastAttempt = time.Now()
+		j.mu.Unlock()
+
+		keys, err := j.load()
+		if err != nil {
+			slog.Warn("Could not refresh JWKS", "error", err)
+			return nil, err
+		}
+		j.mu.Lock()
+		j.keys = keys
+		j.fetchedAt = time.Now()
+		j.mu.Unlock()
+		return nil, nil
+	})
+}
+
+// lookup busca la clave en la caché; requiere j.mu
+func (j *JWKS) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+func (j *JWKS) load() (map[string]interface{}, error) {
+	data, err := j.fetch()
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(data)
+}
+
+func (j *JWKS) fetch() ([]byte, error) {
+	if j.File != "" {
+		return os.ReadFile(j.File)
+	}
+
+	client := j.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Get(j.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS endpoint responded %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS convierte las claves RSA y EC de firma a claves públicas de crypto; las demás se ignoran
+func parseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+
+	keys := map[string]interface{}{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var (
+			key interface{}
+			err error
+		)
+		switch jwk.Kty {
+		case "RSA":
+			key, err = rsaKey(jwk)
+		case "EC":
+			key, err = ecKey(jwk)
+		default:
+			continue
+		}
+		if err != nil {
+			slog.Warn("Skipping JWKS key", "kid", jwk.Kid, "error", err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS contains no usable signing keys")
+	}
+	return keys, nil
+}
+
+func rsaKey(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %w", err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, errors.New("invalid exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+func ecKey(jwk jsonWebKey) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, fmt.Errorf("invalid x: %w", err)
+	}
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	if err != nil {
+		return nil, fmt.Errorf("invalid y: %w", err)
+	}
+
+	key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if !curve.IsOnCurve(key.X, key.Y) {
+		return nil, errors.New("point is not on curve")
+	}
+	return key, nil
+}
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jwksStandIn sirve un JWKS con las claves EC publicadas; cada petición espera a que se cierre gate
+type jwksStandIn struct {
+	server  *httptest.Server
+	fetches atomic.Int32
+
+	mu   sync.Mutex
+	gate chan struct{}
+	kids []string
+}
+
+func newJWKSStandIn(t *testing.T, kids ...string) *jwksStandIn {
+	t.Helper()
+	s := &jwksStandIn{gate: make(chan struct{}), kids: kids}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		s.mu.Lock()
+		gate := s.gate
+		s.mu.Unlock()
+		<-gate
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var set struct {
+			Keys []jsonWebKey `json:"keys"`
+		}
+		for _, kid := range s.kids {
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			set.Keys = append(set.Keys, jsonWebKey{
+				Kty: "EC", Kid: kid, Use: "sig", Crv: "P-256",
+				X: base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+				Y: base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *jwksStandIn) publish(kids ...string) {
+	s.mu.Lock()
+	s.kids = kids
+	s.mu.Unlock()
+}
+
+// release deja pasar las peticiones en espera y las siguientes
+func (s *jwksStandIn) release() {
+	s.mu.Lock()
+	close(s.gate)
+	s.mu.Unlock()
+}
+
+// block hace esperar a las siguientes peticiones hasta el próximo release
+func (s *jwksStandIn) block() {
+	s.mu.Lock()
+	s.gate = make(chan struct{})
+	s.mu.Unlock()
+}
+
+func TestJWKSConcurrentUnknownKidFetchesOnce(t *testing.T) {
+	standIn := newJWKSStandIn(t, "k1")
+	jwks := &JWKS{URL: standIn.server.URL, TTL: time.Hour, MinRefresh: time.Minute}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := jwks.Key("k1")
+			errs <- err
+		}()
+	}
+	for standIn.fetches.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond) // el resto de llamadas se une a la descarga en curso
+	standIn.release()
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Key() error = %v", err)
+		}
+	}
+	if got := standIn.fetches.Load(); got != 1 {
+		t.Errorf("fetches = %d, want 1", got)
+	}
+}
+
+func TestJWKSServesCachedKeysDuringRefresh(t *testing.T) {
+	standIn := newJWKSStandIn(t, "k1")
+	jwks := &JWKS{URL: standIn.server.URL, TTL: time.Hour}
+	standIn.release()
+	if _, err := jwks.Key("k1"); err != nil {
+		t.Fatal(err)
+	}
+	standIn.block()
+
+	// Con la caché caducada y la descarga bloqueada, la clave conocida se sirve sin esperar
+	jwks.mu.Lock()
+	jwks.fetchedAt = time.Now().Add(-2 * time.Hour)
+	jwks.mu.Unlock()
+	standIn.publish("k1", "k2")
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := jwks.Key("k1")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Key(k1) error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Key(k1) waited for the JWKS fetch")
+	}
+
+	for standIn.fetches.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := jwks.Key("k2")
+		waiting <- err
+	}()
+	standIn.release()
+	if err := <-waiting; err != nil {
+		t.Errorf("Key(k2) after refresh error = %v", err)
+	}
+	if _, err := jwks.Key("k3"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Key(k3) error = %v, want ErrUnknownKey", err)
+	}
+}
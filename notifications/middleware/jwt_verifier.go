@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"notifications/config"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTVerifier valida JWT de usuario firmados con HMAC (uno o varios secretos activos) o con
+// RSA/ECDSA (claves del JWKS elegidas por kid), además de iss, aud, exp (obligatorio) y nbf
+type JWTVerifier struct {
+	// Secrets son los secretos HMAC aceptados; durante una rotación conviven el nuevo y el anterior
+	Secrets [][]byte
+	// JWKS aporta las claves públicas para RS*, PS* y ES*; nil desactiva los algoritmos asimétricos
+	JWKS     *JWKS
+	Issuer   string
+	Audience string
+	// Leeway tolera diferencias de reloj en exp y nbf
+	Leeway time.Duration
+}
+
+var (
+	hmacMethods       = []string{"HS256", "HS384", "HS512"}
+	asymmetricMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+)
+
+// Parse valida el token y rellena claims
+func (v *JWTVerifier) Parse(tokenString string, claims jwt.Claims) error {
+	// Sin WithExpirationRequired un token sin exp se aceptaría para siempre
+	options := []jwt.ParserOption{jwt.WithValidMethods(v.methods()), jwt.WithLeeway(v.Leeway), jwt.WithExpirationRequired()}
+	if v.Issuer != "" {
+		options = append(options, jwt.WithIssuer(v.Issuer))
+	}
+	if v.Audience != "" {
+		options = append(options, jwt.WithAudience(v.Audience))
+	}
+
+	_, err := jwt.ParseWithClaims(tokenString, claims, v.keyFunc, options...)
+	return err
+}
+
+// methods limita los algoritmos a los que tienen clave configurada; así un token HS256 nunca
+// se verifica con una clave pública usada como secreto
+func (v *JWTVerifier) methods() []string {
+	var methods []string
+	if len(v.Secrets) > 0 {
+		methods = append(methods, hmacMethods...)
+	}
+	if v.JWKS != nil {
+		methods = append(methods, asymmetricMethods...)
+	}
+	return methods
+}
+
+func (v *JWTVerifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		set := jwt.VerificationKeySet{}
+		for _, secret := range v.Secrets {
+			set.Keys = append(set.Keys, secret)
+		}
+		return set, nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		if v.JWKS == nil {
+			return nil, errors.New("asymmetric tokens are not accepted")
+		}
+		kid, _ := token.Header["kid"].(string)
+		return v.JWKS.Key(kid)
+	default:
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+}
+
+// JWTVerifierFromEnv construye el verificador a partir de:
+//
+//	JWT_SECRET / JWT_SECRET_PREVIOUS              secretos HMAC activos
+//	JWT_JWKS_URL o JWT_JWKS_FILE                  claves públicas (RS256, ES256, ...)
+//	JWT_JWKS_CACHE_TTL                            vigencia de la caché del JWKS (10m)
+//	JWT_ISSUER / JWT_AUDIENCE / JWT_LEEWAY        validación de iss, aud y margen de reloj (30s)
+func JWTVerifierFromEnv() *JWTVerifier {
+	v := &JWTVerifier{
+		Issuer:   config.GetEnv("JWT_ISSUER", ""),
+		Audience: config.GetEnv("JWT_AUDIENCE", ""),
+		Leeway:   config.GetDurationEnv("JWT_LEEWAY", 30*time.Second),
+	}
+
+	for _, key := range []string{"JWT_SECRET", "JWT_SECRET_PREVIOUS"} {
+		if secret := config.GetEnv(key, ""); secret != "" {
+			v.Secrets = append(v.Secrets, []byte(secret))
+		}
+	}
+
+	url, file := config.GetEnv("JWT_JWKS_URL", ""), config.GetEnv("JWT_JWKS_FILE", "")
+	if url != "" || file != "" {
+		v.JWKS = &JWKS{
+			URL:        url,
+			File:       file,
+			TTL:        config.GetDurationEnv("JWT_JWKS_CACHE_TTL", 10*time.Minute),
+			MinRefresh: 30 * time.Second,
+		}
+	}
+
+	if len(v.Secrets) == 0 && v.JWKS == nil {
+		slog.Warn("Neither JWT_SECRET nor a JWKS is configured; every user token will be rejected")
+	}
+	return v
+}
+
+var (
+	verifier     *JWTVerifier
+	verifierOnce sync.Once
+)
+
+// SetJWTVerifier reemplaza el verificador usado por RequireAuth (p.ej. en pruebas)
+func SetJWTVerifier(v *JWTVerifier) {
+	verifierOnce.Do(func() {})
+	verifier = v
+}
+
+// jwtVerifier se construye al primer uso porque el .env se carga en main
+func jwtVerifier() *JWTVerifier {
+	verifierOnce.Do(func() { verifier = JWTVerifierFromEnv() })
+	return verifier
+}
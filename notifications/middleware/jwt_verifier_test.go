@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWTVerifierRequiresExpiration(t *testing.T) {
+	secret := []byte("test-secret")
+	v := &JWTVerifier{Secrets: [][]byte{secret}}
+	sign := func(claims jwt.MapClaims) string {
+		t.Helper()
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	if err := v.Parse(sign(jwt.MapClaims{"sub": "user"}), jwt.MapClaims{}); !errors.Is(err, jwt.ErrTokenRequiredClaimMissing) {
+		t.Errorf("token without exp: err = %v, want %v", err, jwt.ErrTokenRequiredClaimMissing)
+	}
+	if err := v.Parse(sign(jwt.MapClaims{"sub": "user", "exp": time.Now().Add(-time.Minute).Unix()}), jwt.MapClaims{}); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Errorf("expired token: err = %v, want %v", err, jwt.ErrTokenExpired)
+	}
+	if err := v.Parse(sign(jwt.MapClaims{"sub": "user", "exp": time.Now().Add(time.Minute).Unix()}), jwt.MapClaims{}); err != nil {
+		t.Errorf("valid token: err = %v", err)
+	}
+}
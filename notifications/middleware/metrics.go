@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"notifications/metrics"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPMetrics mide la latencia de cada petición por método, ruta y estado. Como en el log de
+// acceso se usa el patrón de la ruta y no la URL, para no crear una serie por id.
+func HTTPMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"notifications/ratelimit"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// webhookSourceKey guarda en el contexto la fuente ya verificada por VerifyWebhookSignature
+const webhookSourceKey = "webhook.source"
+
+// ProducerKey identifica al productor de una petición de ingesta: la fuente del webhook si la
+// firma ya se verificó o, si no, la IP del cliente
+func ProducerKey(c *gin.Context) string {
+	if source := c.GetString(webhookSourceKey); source != "" {
+		return "webhook:" + source
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// RateLimit aplica un token bucket por productor (ver ProducerKey) y responde 429 con
+// Retry-After cuando se agota. Si el almacén falla la petición pasa: el límite protege el
+// servicio, no debe tumbarlo.
+func RateLimit(store ratelimit.Store, limit ratelimit.Limit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil || !limit.Enabled() {
+			c.Next()
+			return
+		}
+
+		key := ProducerKey(c)
+		decision, err := store.Take(c.Request.Context(), "ingest:"+key, limit)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "Rate limit store unavailable, allowing request", "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit.Burst))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		if !decision.Allowed {
+			slog.WarnContext(c.Request.Context(), "Ingestion rate limit exceeded", "producer", key)
+			c.Header("Retry-After", strconv.Itoa(RetryAfterSeconds(decision)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RetryAfterSeconds redondea hacia arriba la espera de una decisión, con un mínimo de un segundo
+func RetryAfterSeconds(decision ratelimit.Decision) int {
+	return int(math.Max(1, math.Ceil(decision.RetryAfter.Seconds())))
+}
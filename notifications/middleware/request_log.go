@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"log/slog"
+	"notifications/logger"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader es el header con el que se propaga el request ID entre servicios
+const RequestIDHeader = "X-Request-ID"
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// probeRoutes son las rutas que consultan sondas y Prometheus cada pocos segundos
+var probeRoutes = map[string]bool{
+	"/ping":    true,
+	"/health":  true,
+	"/livez":   true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// IsProbeRoute indica si la ruta es de sondas o métricas; sus peticiones correctas se registran
+// en debug y no generan trazas
+func IsProbeRoute(path string) bool {
+	return probeRoutes[path]
+}
+
+// RequestLogger asigna un request ID (el recibido en X-Request-ID si es válido, o uno nuevo),
+// lo devuelve en la respuesta, lo deja en el contexto para los logs de los handlers y escribe
+// una línea de acceso por petición. Se registra la ruta, no la URL, para no volcar parámetros.
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), id))
+
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		// Un 503 de /readyz es un estado esperado, no un error del servidor
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500 && !IsProbeRoute(route):
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		case IsProbeRoute(route):
+			level = slog.LevelDebug
+		}
+		slog.LogAttrs(c.Request.Context(), level, "HTTP request",
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("size", c.Writer.Size()),
+		)
+	}
+}
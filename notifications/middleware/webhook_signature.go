@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"io"
+	"log/slog"
+	"net/http"
+	"notifications/config"
+	"notifications/models"
+	"notifications/webhooks"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// Headers que deben enviar los productores de webhooks
+const (
+	SignatureHeader = "X-Signature"
+	TimestampHeader = "X-Timestamp"
+)
+
+// maxWebhookBodySize limita el cuerpo que se lee para calcular la firma
+const maxWebhookBodySize = 1 << 20
+
+// webhookTolerance es la diferencia máxima aceptada entre X-Timestamp y el reloj del servicio
+func webhookTolerance() time.Duration {
+	return config.GetDurationEnv("WEBHOOK_SIGNATURE_TOLERANCE", 5*time.Minute)
+}
+
+// webhookSourcePattern son minúsculas y dígitos separados por guiones simples, hasta 64 caracteres.
+// Sin "_" ni "--", cada fuente da un nombre de variable distinto y sin "__", así que no puede chocar
+// con el sufijo de rotación (users-svc y users_svc ya no comparten secreto, ni foo-previous con foo).
+var webhookSourcePattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// previousSecretSuffix marca el secreto anterior durante una rotación
+const previousSecretSuffix = "__PREVIOUS"
+
+// ValidWebhookSource indica si source es un nombre de fuente de webhook válido
+func ValidWebhookSource(source string) bool {
+	return len(source) <= 64 && webhookSourcePattern.MatchString(source)
+}
+
+// WebhookSecrets devuelve los secretos activos de una fuente: WEBHOOK_SECRET_<SOURCE> y, durante
+// una rotación, WEBHOOK_SECRET_<SOURCE>__PREVIOUS. Sin secretos o con un nombre inválido la fuente
+// no se acepta.
+func WebhookSecrets(source string) []string {
+	if !ValidWebhookSource(source) {
+		return nil
+	}
+	name := "WEBHOOK_SECRET_" + strings.ToUpper(strings.ReplaceAll(source, "-", "_"))
+
+	var secrets []string
+	for _, key := range []string{name, name + previousSecretSuffix} {
+		if secret := config.GetEnv(key, ""); secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+// VerifyWebhookSignature exige un X-Signature válido sobre el cuerpo crudo:
+// "sha256=" + hex(HMAC-SHA256(secret, X-Timestamp + "." + body)), el mismo esquema que
+// usan los webhooks salientes. La fuente es el parámetro :source o, si la ruta no lo tiene,
+// defaultSource. Cada firma aceptada se guarda como nonce hasta que su timestamp caduca,
+// así que reenviar la misma petición se rechaza aunque esté dentro de la tolerancia. Si la petición
+// no termina en 2xx el nonce se libera: el productor puede reintentar la misma petición firmada.
+func VerifyWebhookSignature(defaultSource string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		source := c.Param("source")
+		if source == "" {
+			source = defaultSource
+		}
+
+		secrets := WebhookSecrets(source)
+		if len(secrets) == 0 {
+			abortWebhook(c, http.StatusUnauthorized, "unknown webhook source")
+			return
+		}
+
+		timestamp, err := strconv.ParseInt(c.GetHeader(TimestampHeader), 10, 64)
+		if err != nil {
+			abortWebhook(c, http.StatusUnauthorized, "missing or invalid "+TimestampHeader+" header")
+			return
+		}
+		tolerance := webhookTolerance()
+		sentAt := time.Unix(timestamp, 0)
+		if skew := time.Since(sentAt); skew > tolerance || skew < -tolerance {
+			abortWebhook(c, http.StatusUnauthorized, "timestamp outside tolerance window")
+			return
+		}
+
+		signature := strings.TrimPrefix(c.GetHeader(SignatureHeader), "sha256=")
+		if signature == "" {
+			abortWebhook(c, http.StatusUnauthorized, "missing "+SignatureHeader+" header")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBodySize))
+		if err != nil {
+			abortWebhook(c, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		if !validSignature(secrets, timestamp, body, signature) {
+			slog.WarnContext(c.Request.Context(), "Rejected webhook: invalid signature", "source", source)
+			abortWebhook(c, http.StatusUnauthorized, "invalid signature")
+			return
+		}
+
+		fresh, err := claimNonce(source, signature, sentAt.Add(tolerance))
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Could not record webhook nonce", "source", source, "error", err)
+			abortWebhook(c, http.StatusInternalServerError, "could not verify request")
+			return
+		}
+		if !fresh {
+			slog.WarnContext(c.Request.Context(), "Rejected replayed webhook", "source", source)
+			abortWebhook(c, http.StatusConflict, "replayed request")
+			return
+		}
+
+		// Un pánico también libera el nonce; Recovery, más arriba, responde el 500
+		defer func() {
+			if r := recover(); r != nil {
+				releaseNonce(c, source, signature)
+				panic(r)
+			}
+		}()
+
+		c.Set(webhookSourceKey, source)
+		c.Next()
+
+		if status := c.Writer.Status(); status < 200 || status >= 300 {
+			releaseNonce(c, source, signature)
+		}
+	}
+}
+
+// validSignature compara en tiempo constante contra cada secreto activo de la fuente
+func validSignature(secrets []string, timestamp int64, body []byte, signature string) bool {
+	for _, secret := range secrets {
+		expected := strings.TrimPrefix(webhooks.Sign(secret, timestamp, body), "sha256=")
+		if hmac.Equal([]byte(expected), []byte(signature)) {
+			return true
+		}
+	}
+	return false
+}
+
+// claimNonce guarda la firma como nonce; devuelve false si ya se había usado.
+// Va en Postgres para que un reenvío a otra réplica también se detecte.
+func claimNonce(source, nonce string, expiresAt time.Time) (bool, error) {
+	result := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.WebhookNonce{
+		Source:    source,
+		Nonce:     nonce,
+		ExpiresAt: expiresAt,
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// releaseNonce borra el nonce de una petición que no se completó (límite de tasa, validación o error
+// interno) para que un reintento idéntico no se rechace como reenvío
+func releaseNonce(c *gin.Context, source, nonce string) {
+	err := config.DB.WithContext(context.WithoutCancel(c.Request.Context())).
+		Where("source = ? AND nonce = ?", source, nonce).
+		Delete(&models.WebhookNonce{}).Error
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Could not release webhook nonce", "source", source, "error", err)
+	}
+}
+
+// PurgeExpiredWebhookNonces borra periódicamente los nonces cuyo timestamp ya no se aceptaría
+func PurgeExpiredWebhookNonces(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := config.DB.Where(`"expiresAt" < ?`, time.Now()).Delete(&models.WebhookNonce{}).Error; err != nil {
+			slog.Error("Error purging expired webhook nonces", "error", err)
+		}
+	}
+}
+
+func abortWebhook(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, gin.H{"error": message})
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"notifications/config"
+	"notifications/internal/testdb"
+	"notifications/webhooks"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWebhookNonceReleasedWhenHandlerFails(t *testing.T) {
+	db := testdb.Open(t)
+	prevDB := config.DB
+	config.DB = db
+	t.Cleanup(func() { config.DB = prevDB })
+	t.Setenv("WEBHOOK_SECRET_BILLING", "billing-secret")
+
+	gin.SetMode(gin.TestMode)
+	statuses := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusCreated}
+	calls := 0
+	r := gin.New()
+	r.POST("/webhook/:source", VerifyWebhookSignature(""), func(c *gin.Context) {
+		c.Status(statuses[calls])
+		calls++
+	})
+
+	body := `{"event":"invoice_paid"}`
+	timestamp := time.Now().Unix()
+	signature := webhooks.Sign("billing-secret", timestamp, []byte(body))
+	send := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/webhook/billing", strings.NewReader(body))
+		req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(SignatureHeader, signature)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Los reintentos idénticos tras un 503 y un 429 llegan al handler
+	for i, want := range statuses {
+		if got := send(); got != want {
+			t.Fatalf("attempt %d: status %d, want %d", i+1, got, want)
+		}
+	}
+	// Tras el 2xx el nonce queda registrado y la misma petición es un reenvío
+	if got := send(); got != http.StatusConflict {
+		t.Errorf("replay after success: status %d, want %d", got, http.StatusConflict)
+	}
+	if calls != len(statuses) {
+		t.Errorf("handler called %d times, want %d", calls, len(statuses))
+	}
+}
+
+func TestWebhookSecretsDoNotCollide(t *testing.T) {
+	t.Setenv("WEBHOOK_SECRET_USERS_SVC", "users-svc-secret")
+	t.Setenv("WEBHOOK_SECRET_FOO", "foo-secret")
+	t.Setenv("WEBHOOK_SECRET_FOO__PREVIOUS", "foo-old-secret")
+	t.Setenv("WEBHOOK_SECRET_FOO_PREVIOUS", "foo-previous-secret")
+
+	cases := map[string][]string{
+		"users-svc":    {"users-svc-secret"},
+		"users_svc":    nil, // "_" no es válido: no comparte el secreto de users-svc
+		"foo":          {"foo-secret", "foo-old-secret"},
+		"foo_previous": nil,                     // no resuelve al secreto de rotación de foo
+		"foo-previous": {"foo-previous-secret"}, // fuente propia, nunca la rotación de foo
+		"foo--bar":     nil,
+		"Foo":          nil,
+		"-foo":         nil,
+		"":             nil,
+	}
+	for source, want := range cases {
+		got := WebhookSecrets(source)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("WebhookSecrets(%q) = %v, want %v", source, got, want)
+		}
+	}
+}
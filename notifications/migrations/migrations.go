@@ -0,0 +1,307 @@
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed sql/*.sql
+var sqlFiles embed.FS
+
+// lockID identifica el advisory lock de Postgres que serializa las migraciones entre réplicas
+const lockID = 727001
+
+// baselineVersion es la migración que adopta la tabla "Notifications" creada antes de tener
+// migraciones; no tiene script de bajada porque revertirla borraría todos los datos.
+const baselineVersion = 1
+
+// noTransactionDirective en la primera línea de un script lo ejecuta fuera de transacción, sentencia
+// a sentencia; lo necesitan operaciones como CREATE INDEX CONCURRENTLY. Cada sentencia debe terminar
+// en ";" al final de una línea.
+const noTransactionDirective = "-- migrate:no-transaction"
+
+// Migration es una migración versionada con sus scripts de subida y bajada
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// SchemaMigration es la fila de la tabla de versiones aplicadas
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"type:text"`
+	AppliedAt time.Time `gorm:"type:timestamp;column:appliedAt"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "SchemaMigrations"
+}
+
+// Status es el estado de una migración para el comando "migrate status"
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Load lee las migraciones embebidas (NNNN_nombre.up.sql / NNNN_nombre.down.sql) ordenadas por versión
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(sqlFiles, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionStr, label, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", name)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", name, err)
+		}
+
+		content, err := sqlFiles.ReadFile(path.Join("sql", name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		} else if m.Name != label {
+			return nil, fmt.Errorf("migration %d has conflicting names: %s and %s", version, m.Name, label)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up aplica todas las migraciones pendientes
+func Up(db *gorm.DB) error {
+	migrations, err := Load()
+	if err != nil {
+		return err
+	}
+
+	return withLock(db, func(conn *gorm.DB) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			slog.Info("Applying migration", "version", m.Version, "name", m.Name)
+			err := run(conn, m.Up, func(tx *gorm.DB) error {
+				return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Down revierte las últimas `steps` migraciones aplicadas
+func Down(db *gorm.DB, steps int) error {
+	migrations, err := Load()
+	if err != nil {
+		return err
+	}
+
+	return withLock(db, func(conn *gorm.DB) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		plan, err := downPlan(migrations, applied, steps)
+		if err != nil {
+			return err
+		}
+		for _, m := range plan {
+			slog.Info("Reverting migration", "version", m.Version, "name", m.Name)
+			err := run(conn, m.Down, func(tx *gorm.DB) error {
+				return tx.Delete(&SchemaMigration{}, m.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("revert of %04d_%s failed: %w", m.Version, m.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// downPlan elige las últimas `steps` migraciones aplicadas, de la más reciente a la más antigua, y
+// comprueba antes de revertir ninguna que todas tienen script de bajada: pedir demasiados pasos falla
+// sin tocar el esquema en lugar de quedarse a medias al llegar a la línea base.
+func downPlan(migrations []Migration, applied map[int]SchemaMigration, steps int) ([]Migration, error) {
+	var plan []Migration
+	for i := len(migrations) - 1; i >= 0 && len(plan) < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Version == baselineVersion {
+			return nil, fmt.Errorf("migration %04d_%s is the baseline and cannot be reverted", m.Version, m.Name)
+		}
+		if m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no down script", m.Version, m.Name)
+		}
+		plan = append(plan, m)
+	}
+	return plan, nil
+}
+
+// run ejecuta un script y después record. Normalmente todo va en una transacción; con
+// noTransactionDirective cada sentencia va por separado y record solo se ejecuta si todas terminaron,
+// así que un fallo a mitad deja la migración pendiente y el script debe poder repetirse.
+func run(conn *gorm.DB, script string, record func(tx *gorm.DB) error) error {
+	if !strings.HasPrefix(strings.TrimSpace(script), noTransactionDirective) {
+		return conn.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(script).Error; err != nil {
+				return err
+			}
+			return record(tx)
+		})
+	}
+
+	for _, statement := range splitStatements(script) {
+		if err := conn.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return record(conn)
+}
+
+// splitStatements separa un script en sentencias terminadas en ";" al final de línea, sin comentarios.
+// Postgres ejecuta varias sentencias enviadas juntas en una transacción implícita, por eso no basta
+// con enviar el script entero.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// GetStatus devuelve cada migración conocida indicando si está aplicada
+func GetStatus(db *gorm.DB) ([]Status, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureTable(db); err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, m := range migrations {
+		s := Status{Migration: m}
+		if row, ok := applied[m.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = row.AppliedAt
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// withLock ejecuta fn en una única conexión que mantiene el advisory lock,
+// de modo que solo una réplica aplica migraciones a la vez
+func withLock(db *gorm.DB, fn func(conn *gorm.DB) error) error {
+	return db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockID).Error; err != nil {
+			return fmt.Errorf("could not acquire migration lock: %w", err)
+		}
+		defer func() {
+			if err := conn.Exec("SELECT pg_advisory_unlock(?)", lockID).Error; err != nil {
+				slog.Error("Could not release migration lock", "error", err)
+			}
+		}()
+
+		if err := ensureTable(conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+func ensureTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS "SchemaMigrations" (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		"appliedAt" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+	)`).Error
+}
+
+func appliedVersions(db *gorm.DB) (map[int]SchemaMigration, error) {
+	var rows []SchemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
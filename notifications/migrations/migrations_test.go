@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := noTransactionDirective + `
+-- comentario; con punto y coma
+DROP INDEX CONCURRENTLY IF EXISTS "a";
+CREATE INDEX CONCURRENTLY "a"
+    ON "T" (x)
+    WHERE y = ';';
+
+SELECT 1`
+	want := []string{
+		`DROP INDEX CONCURRENTLY IF EXISTS "a";`,
+		"CREATE INDEX CONCURRENTLY \"a\"\n    ON \"T\" (x)\n    WHERE y = ';';",
+		"SELECT 1",
+	}
+	if got := splitStatements(script); !reflect.DeepEqual(got, want) {
+		t.Errorf("splitStatements() = %q, want %q", got, want)
+	}
+}
+
+func TestListingIndexesAreConcurrent(t *testing.T) {
+	all, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range all {
+		if m.Version != 5 {
+			continue
+		}
+		for _, script := range []string{m.Up, m.Down} {
+			if !strings.HasPrefix(script, noTransactionDirective) {
+				t.Errorf("0005 must run outside a transaction to use CONCURRENTLY")
+			}
+			for _, statement := range splitStatements(script) {
+				if !strings.Contains(statement, "CONCURRENTLY") {
+					t.Errorf("statement locks writes on Notifications: %s", statement)
+				}
+			}
+		}
+		return
+	}
+	t.Fatal("migration 0005 not found")
+}
+
+func TestDownPlanStopsAtBaseline(t *testing.T) {
+	all, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all[0].Version != baselineVersion || all[0].Down != "" {
+		t.Fatalf("baseline %04d_%s must not have a down script", all[0].Version, all[0].Name)
+	}
+	applied := map[int]SchemaMigration{}
+	for _, m := range all[:3] {
+		applied[m.Version] = SchemaMigration{Version: m.Version}
+	}
+
+	plan, err := downPlan(all, applied, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 2 || plan[0].Version != all[2].Version || plan[1].Version != all[1].Version {
+		t.Errorf("downPlan(2) = %v, want the two latest applied migrations", plan)
+	}
+	if _, err := downPlan(all, applied, 3); err == nil || !strings.Contains(err.Error(), "baseline") {
+		t.Errorf("downPlan(3) error = %v, want baseline rejection", err)
+	}
+}
@@ -0,0 +1,12 @@
+-- Tabla base de notificaciones. IF NOT EXISTS porque en entornos existentes
+-- la tabla fue creada antes de tener migraciones.
+CREATE TABLE IF NOT EXISTS "Notifications" (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    "actorId" UUID NOT NULL,
+    "recipientId" UUID NOT NULL,
+    "responsibleId" UUID NOT NULL,
+    type VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    read BOOLEAN NOT NULL DEFAULT FALSE,
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS "idx_Notifications_deletedAt";
+
+ALTER TABLE "Notifications"
+    DROP COLUMN IF EXISTS "deletedAt",
+    DROP COLUMN IF EXISTS "archivedAt",
+    DROP COLUMN IF EXISTS archived;
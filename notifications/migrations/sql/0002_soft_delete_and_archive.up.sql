@@ -0,0 +1,6 @@
+ALTER TABLE "Notifications"
+    ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS "archivedAt" TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS "deletedAt" TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS "idx_Notifications_deletedAt" ON "Notifications" ("deletedAt");
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS "idx_Notifications_groupId";
+
+ALTER TABLE "Notifications"
+    DROP COLUMN IF EXISTS "groupId",
+    DROP COLUMN IF EXISTS target;
+
+DROP TABLE IF EXISTS "NotificationGroups";
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS "NotificationGroups" (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    "responsibleId" UUID NOT NULL,
+    type VARCHAR(255) NOT NULL,
+    target TEXT NOT NULL,
+    "actorIds" JSONB NOT NULL DEFAULT '[]',
+    "actorCount" INTEGER NOT NULL DEFAULT 0,
+    count INTEGER NOT NULL DEFAULT 0,
+    "latestNotificationId" UUID,
+    "firstAt" TIMESTAMP WITH TIME ZONE NOT NULL,
+    "lastAt" TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Búsqueda del grupo abierto en aggregateNotification
+CREATE INDEX IF NOT EXISTS "idx_NotificationGroups_key"
+    ON "NotificationGroups" ("responsibleId", type, target, "firstAt" DESC);
+
+CREATE INDEX IF NOT EXISTS "idx_NotificationGroups_latestNotificationId"
+    ON "NotificationGroups" ("latestNotificationId");
+
+ALTER TABLE "Notifications"
+    ADD COLUMN IF NOT EXISTS target TEXT,
+    ADD COLUMN IF NOT EXISTS "groupId" UUID REFERENCES "NotificationGroups" (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS "idx_Notifications_groupId" ON "Notifications" ("groupId");
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS "IdempotencyKeys";
+
+DROP INDEX IF EXISTS "idx_Notifications_dedupeKey";
+
+ALTER TABLE "Notifications"
+    DROP COLUMN IF EXISTS "dedupeKey";
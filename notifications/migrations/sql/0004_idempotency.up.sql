@@ -0,0 +1,16 @@
+-- Respaldo del ON CONFLICT ("dedupeKey") usado por FollowCreated
+ALTER TABLE "Notifications"
+    ADD COLUMN IF NOT EXISTS "dedupeKey" TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_Notifications_dedupeKey" ON "Notifications" ("dedupeKey");
+
+CREATE TABLE IF NOT EXISTS "IdempotencyKeys" (
+    scope TEXT NOT NULL,
+    "key" TEXT NOT NULL,
+    "notificationId" UUID,
+    "createdAt" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    "expiresAt" TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (scope, "key")
+);
+
+CREATE INDEX IF NOT EXISTS "idx_IdempotencyKeys_expiresAt" ON "IdempotencyKeys" ("expiresAt");
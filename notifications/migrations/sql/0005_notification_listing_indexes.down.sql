@@ -0,0 +1,3 @@
+-- migrate:no-transaction
+DROP INDEX CONCURRENTLY IF EXISTS "idx_Notifications_responsibleId_unread";
+DROP INDEX CONCURRENTLY IF EXISTS "idx_Notifications_responsibleId_timestamp";
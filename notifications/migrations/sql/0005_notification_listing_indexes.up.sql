@@ -0,0 +1,18 @@
+-- migrate:no-transaction
+-- CONCURRENTLY no bloquea las escrituras en "Notifications" mientras se construye el índice, pero no
+-- puede ir dentro de una transacción. Si la construcción falla queda un índice INVALID con el mismo
+-- nombre; por eso se borra antes de crearlo y el script se puede repetir sin más.
+
+-- Listado (GetNotifications): "responsibleId" = $1 ORDER BY timestamp DESC LIMIT n,
+-- siempre con "deletedAt" IS NULL por el borrado lógico de GORM.
+DROP INDEX CONCURRENTLY IF EXISTS "idx_Notifications_responsibleId_timestamp";
+CREATE INDEX CONCURRENTLY "idx_Notifications_responsibleId_timestamp"
+    ON "Notifications" ("responsibleId", timestamp DESC)
+    WHERE "deletedAt" IS NULL;
+
+-- Solo no leídas y no archivadas: GetNotifications?unread=true y sendPendingNotifications
+-- (que además filtra timestamp >= now() - 30 días sobre el mismo orden).
+DROP INDEX CONCURRENTLY IF EXISTS "idx_Notifications_responsibleId_unread";
+CREATE INDEX CONCURRENTLY "idx_Notifications_responsibleId_unread"
+    ON "Notifications" ("responsibleId", timestamp DESC)
+    WHERE read = FALSE AND archived = FALSE AND "deletedAt" IS NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "MuteRules";
+DROP TABLE IF EXISTS "NotificationPreferences";
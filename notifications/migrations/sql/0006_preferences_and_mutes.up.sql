@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS "NotificationPreferences" (
+    "userId" UUID NOT NULL,
+    type VARCHAR(255) NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    channels JSONB NOT NULL DEFAULT '["websocket"]',
+    "quietHoursStart" VARCHAR(5),
+    "quietHoursEnd" VARCHAR(5),
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    "updatedAt" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("userId", type)
+);
+
+CREATE TABLE IF NOT EXISTS "MuteRules" (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    "userId" UUID NOT NULL,
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('actor', 'target')),
+    value TEXT NOT NULL,
+    "expiresAt" TIMESTAMP WITH TIME ZONE,
+    "createdAt" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS "idx_MuteRules_userId" ON "MuteRules" ("userId");
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS "DigestItems" (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    "userId" UUID NOT NULL,
+    "notificationId" UUID NOT NULL REFERENCES "Notifications" (id) ON DELETE CASCADE,
+    "deliverAt" TIMESTAMP WITH TIME ZONE NOT NULL,
+    "deliveredAt" TIMESTAMP WITH TIME ZONE,
+    "createdAt" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Elementos vencidos pendientes de entregar (RunOnce del scheduler)
+CREATE INDEX IF NOT EXISTS "idx_DigestItems_due"
+    ON "DigestItems" ("deliverAt")
+    WHERE "deliveredAt" IS NULL;
+
+-- Exclusión de retenidas en sendPendingNotifications
+CREATE INDEX IF NOT EXISTS "idx_DigestItems_pending_notificationId"
+    ON "DigestItems" ("notificationId")
+    WHERE "deliveredAt" IS NULL;
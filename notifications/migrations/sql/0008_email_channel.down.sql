@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "EmailDeliveries";
+DROP TABLE IF EXISTS "UserPresence";
+DROP TABLE IF EXISTS "UserContacts";
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS "UserContacts" (
+    "userId" UUID PRIMARY KEY,
+    email TEXT NOT NULL DEFAULT '',
+    "updatedAt" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS "UserPresence" (
+    "userId" UUID PRIMARY KEY,
+    connected BOOLEAN NOT NULL DEFAULT FALSE,
+    "lastSeenAt" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS "EmailDeliveries" (
+    "notificationId" UUID PRIMARY KEY REFERENCES "Notifications" (id) ON DELETE CASCADE,
+    "userId" UUID NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    "lastError" TEXT NOT NULL DEFAULT '',
+    "createdAt" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    "sentAt" TIMESTAMP WITH TIME ZONE
+);
+
+-- Límite por usuario: emails enviados en la última hora
+CREATE INDEX IF NOT EXISTS "idx_EmailDeliveries_userId_sentAt"
+    ON "EmailDeliveries" ("userId", "sentAt")
+    WHERE status = 'sent';
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS "DeviceTokens" (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    "userId" UUID NOT NULL,
+    token TEXT NOT NULL UNIQUE,
+    platform VARCHAR(16) NOT NULL CHECK (platform IN ('android', 'ios', 'web')),
+    "createdAt" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    "lastUsedAt" TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS "idx_DeviceTokens_userId" ON "DeviceTokens" ("userId");
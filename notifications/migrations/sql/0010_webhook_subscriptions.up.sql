@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS "WebhookSubscriptions" (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    "userId" UUID NOT NULL,
+    url TEXT NOT NULL,
+    "eventTypes" JSONB NOT NULL DEFAULT '[]',
+    secret TEXT NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    "createdAt" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS "idx_WebhookSubscriptions_userId" ON "WebhookSubscriptions" ("userId") WHERE active;
+
+CREATE TABLE IF NOT EXISTS "WebhookDeliveries" (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    "subscriptionId" UUID NOT NULL REFERENCES "WebhookSubscriptions" (id) ON DELETE CASCADE,
+    "notificationId" UUID NOT NULL,
+    event VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL CHECK (status IN ('pending', 'succeeded', 'dead_letter')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    "nextAttemptAt" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    "lastStatusCode" INTEGER NOT NULL DEFAULT 0,
+    "lastError" TEXT NOT NULL DEFAULT '',
+    "createdAt" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    "deliveredAt" TIMESTAMP WITH TIME ZONE
+);
+
+-- El dispatcher solo recorre las entregas pendientes por fecha de reintento
+CREATE INDEX IF NOT EXISTS "idx_WebhookDeliveries_due" ON "WebhookDeliveries" ("nextAttemptAt") WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS "idx_WebhookDeliveries_subscription" ON "WebhookDeliveries" ("subscriptionId", "createdAt" DESC);
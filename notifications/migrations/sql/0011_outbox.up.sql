@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS "OutboxEvents" (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    "notificationId" UUID NOT NULL,
+    channel VARCHAR(32) NOT NULL,
+    status VARCHAR(16) NOT NULL CHECK (status IN ('pending', 'done', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    "nextAttemptAt" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    "lastError" TEXT NOT NULL DEFAULT '',
+    "createdAt" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    "processedAt" TIMESTAMP WITH TIME ZONE
+);
+
+-- El relay solo recorre los eventos pendientes por fecha de reintento
+CREATE INDEX IF NOT EXISTS "idx_OutboxEvents_due" ON "OutboxEvents" ("nextAttemptAt") WHERE status = 'pending';
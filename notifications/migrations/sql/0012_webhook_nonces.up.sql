@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS "WebhookNonces" (
+    source VARCHAR(64) NOT NULL,
+    nonce VARCHAR(128) NOT NULL,
+    "expiresAt" TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (source, nonce)
+);
+
+CREATE INDEX IF NOT EXISTS "idx_WebhookNonces_expiresAt" ON "WebhookNonces" ("expiresAt");
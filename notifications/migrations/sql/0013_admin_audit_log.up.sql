@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS "AdminAuditLogs" (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    "adminId" UUID NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    "targetUserId" UUID,
+    "notificationId" UUID,
+    details JSONB NOT NULL DEFAULT '{}',
+    "requestId" VARCHAR(128) NOT NULL DEFAULT '',
+    "createdAt" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS "idx_AdminAuditLogs_createdAt" ON "AdminAuditLogs" ("createdAt" DESC);
+CREATE INDEX IF NOT EXISTS "idx_AdminAuditLogs_adminId" ON "AdminAuditLogs" ("adminId", "createdAt" DESC);
+CREATE INDEX IF NOT EXISTS "idx_AdminAuditLogs_targetUserId" ON "AdminAuditLogs" ("targetUserId", "createdAt" DESC);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "NotificationEvents";
+DROP FUNCTION IF EXISTS "NotificationEvents_append_only"();
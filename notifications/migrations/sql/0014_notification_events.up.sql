@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS "NotificationEvents" (
+    id BIGSERIAL PRIMARY KEY,
+    "notificationId" UUID NOT NULL,
+    "userId" UUID NOT NULL,
+    event VARCHAR(32) NOT NULL,
+    channel VARCHAR(32) NOT NULL DEFAULT '',
+    result VARCHAR(32) NOT NULL DEFAULT '',
+    detail TEXT NOT NULL DEFAULT '',
+    "createdAt" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Sin clave foránea: el historial se conserva aunque la notificación se borre
+CREATE INDEX IF NOT EXISTS "idx_NotificationEvents_notificationId" ON "NotificationEvents" ("notificationId", id);
+
+-- Solo inserción: el historial no se puede modificar ni borrar
+CREATE OR REPLACE FUNCTION "NotificationEvents_append_only"() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION '"NotificationEvents" is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS "NotificationEvents_append_only" ON "NotificationEvents";
+CREATE TRIGGER "NotificationEvents_append_only"
+    BEFORE UPDATE OR DELETE ON "NotificationEvents"
+    FOR EACH ROW EXECUTE FUNCTION "NotificationEvents_append_only"();
@@ -0,0 +1,8 @@
+-- UNLOGGED: perder los buckets en una caída solo reinicia los límites y ahorra escrituras al WAL
+CREATE UNLOGGED TABLE IF NOT EXISTS "RateLimitBuckets" (
+    key VARCHAR(255) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    "updatedAt" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS "idx_RateLimitBuckets_updatedAt" ON "RateLimitBuckets" ("updatedAt");
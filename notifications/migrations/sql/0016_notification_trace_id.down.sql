@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "idx_Notifications_traceId";
+
+ALTER TABLE "Notifications"
+    DROP COLUMN IF EXISTS "traceId";
@@ -0,0 +1,5 @@
+-- Trace ID (W3C, 32 caracteres hex) de la petición que creó la notificación, para enlazar la entrega con su origen
+ALTER TABLE "Notifications"
+    ADD COLUMN IF NOT EXISTS "traceId" VARCHAR(32);
+
+CREATE INDEX IF NOT EXISTS "idx_Notifications_traceId" ON "Notifications" ("traceId") WHERE "traceId" IS NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE "DigestItems"
+    DROP COLUMN IF EXISTS "claimedUntil";
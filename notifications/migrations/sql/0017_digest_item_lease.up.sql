@@ -0,0 +1,4 @@
+-- Lease de los elementos reclamados por el scheduler: el resumen se entrega fuera de la transacción que
+-- los reclama y, si la réplica muere antes de marcarlos, vuelven a estar disponibles al vencer
+ALTER TABLE "DigestItems"
+    ADD COLUMN IF NOT EXISTS "claimedUntil" TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,6 @@
+-- migrate:no-transaction
+-- Falla si ya hay filas eliminadas y activas con la misma clave; habría que purgar las eliminadas antes
+CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS "idx_Notifications_dedupeKey"
+    ON "Notifications" ("dedupeKey");
+
+DROP INDEX CONCURRENTLY IF EXISTS "idx_Notifications_dedupeKey_active";
@@ -0,0 +1,9 @@
+-- migrate:no-transaction
+-- La deduplicación solo aplica a notificaciones no eliminadas: un nuevo follow tras borrar la
+-- notificación anterior crea otra en lugar de actualizar la fila borrada. El índice nuevo se crea
+-- antes de borrar el anterior para no quedar sin unicidad entre medias.
+CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS "idx_Notifications_dedupeKey_active"
+    ON "Notifications" ("dedupeKey")
+    WHERE "deletedAt" IS NULL;
+
+DROP INDEX CONCURRENTLY IF EXISTS "idx_Notifications_dedupeKey";
@@ -0,0 +1,2 @@
+ALTER TABLE "EmailDeliveries"
+    DROP COLUMN IF EXISTS "claimedAt";
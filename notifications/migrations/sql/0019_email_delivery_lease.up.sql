@@ -0,0 +1,6 @@
+-- Lease de las entregas en curso: una fila que se queda en 'sending' (la réplica murió a mitad del
+-- envío) se vuelve a reclamar cuando "claimedAt" supera el lease del worker
+ALTER TABLE "EmailDeliveries"
+    ADD COLUMN IF NOT EXISTS "claimedAt" TIMESTAMP WITH TIME ZONE;
+
+UPDATE "EmailDeliveries" SET "claimedAt" = "createdAt" WHERE status = 'sending';
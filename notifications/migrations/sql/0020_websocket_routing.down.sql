@@ -0,0 +1,5 @@
+ALTER TABLE "OutboxEvents"
+    DROP COLUMN IF EXISTS "replicaId";
+
+ALTER TABLE "UserPresence"
+    DROP COLUMN IF EXISTS "replicaId";
@@ -0,0 +1,7 @@
+-- Réplica que tiene abierta la conexión WebSocket del usuario y réplica a la que se encaminó un
+-- evento del outbox para que lo publique ella
+ALTER TABLE "UserPresence"
+    ADD COLUMN IF NOT EXISTS "replicaId" TEXT;
+
+ALTER TABLE "OutboxEvents"
+    ADD COLUMN IF NOT EXISTS "replicaId" TEXT;
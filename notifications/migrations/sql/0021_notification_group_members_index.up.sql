@@ -0,0 +1,7 @@
+-- migrate:no-transaction
+-- Miembros de un grupo por antigüedad: el listado agrupado busca, para cada fila, si hay otra más
+-- reciente del mismo grupo que cumpla los filtros (ver latestOfGroupScope)
+DROP INDEX CONCURRENTLY IF EXISTS "idx_Notifications_groupId_timestamp";
+CREATE INDEX CONCURRENTLY "idx_Notifications_groupId_timestamp"
+    ON "Notifications" ("groupId", timestamp DESC)
+    WHERE "groupId" IS NOT NULL AND "deletedAt" IS NULL;
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AdminAuditLog registra cada acción del API de administración: quién la hizo, sobre qué
+// usuario o notificación y con qué parámetros
+type AdminAuditLog struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	AdminID        uuid.UUID  `gorm:"type:uuid;column:adminId"`
+	Action         string     `gorm:"type:string"`
+	TargetUserID   *uuid.UUID `gorm:"type:uuid;column:targetUserId"`
+	NotificationID *uuid.UUID `gorm:"type:uuid;column:notificationId"`
+	Details        string     `gorm:"type:jsonb"`
+	RequestID      string     `gorm:"type:string;column:requestId"`
+	CreatedAt      time.Time  `gorm:"type:timestamp;column:createdAt"`
+}
+
+func (AdminAuditLog) TableName() string {
+	return "AdminAuditLogs"
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Plataformas de dispositivos para push
+const (
+	PlatformAndroid = "android"
+	PlatformIOS     = "ios"
+	PlatformWeb     = "web"
+)
+
+// DeviceToken es un token de push registrado por un usuario para uno de sus dispositivos
+type DeviceToken struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UserID     uuid.UUID  `gorm:"type:uuid;column:userId;index"`
+	Token      string     `gorm:"type:text;uniqueIndex"`
+	Platform   string     `gorm:"type:string"`
+	CreatedAt  time.Time  `gorm:"type:timestamp;column:createdAt"`
+	LastUsedAt *time.Time `gorm:"type:timestamp;column:lastUsedAt"`
+}
+
+func (DeviceToken) TableName() string {
+	return "DeviceTokens"
+}
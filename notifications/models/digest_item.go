@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DigestItem es una notificación retenida durante el horario de silencio del usuario
+// que se entregará en un resumen al terminar la ventana (DeliverAt)
+type DigestItem struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UserID         uuid.UUID  `gorm:"type:uuid;column:userId"`
+	NotificationID uuid.UUID  `gorm:"type:uuid;column:notificationId"`
+	DeliverAt      time.Time  `gorm:"type:timestamp;column:deliverAt"`
+	DeliveredAt    *time.Time `gorm:"type:timestamp;column:deliveredAt"`
+	// ClaimedUntil es el fin del lease del scheduler que lo reclamó; hace de token al marcarlo
+	ClaimedUntil *time.Time `gorm:"type:timestamp;column:claimedUntil"`
+	CreatedAt    time.Time  `gorm:"type:timestamp;column:createdAt"`
+}
+
+func (DigestItem) TableName() string {
+	return "DigestItems"
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyKey guarda el resultado de una petición de ingesta para devolverlo en reintentos.
+// Scope identifica el origen (ej. "webhook:like", "grpc:FollowCreated").
+type IdempotencyKey struct {
+	Scope          string     `gorm:"type:text;primaryKey"`
+	Key            string     `gorm:"type:text;primaryKey;column:key"`
+	NotificationID *uuid.UUID `gorm:"type:uuid;column:notificationId"`
+	CreatedAt      time.Time  `gorm:"type:timestamp;column:createdAt"`
+	ExpiresAt      time.Time  `gorm:"type:timestamp;column:expiresAt;index"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "IdempotencyKeys"
+}
@@ -16,6 +16,21 @@ type Notification struct {
 	Content       string    `gorm:"type:text"`
 	Read          bool      `gorm:"type:boolean;default:false"`
 	Timestamp     time.Time `gorm:"type:timestamp"`
+
+	// Objeto sobre el que se actúa (ej. id del post) y grupo al que pertenece
+	Target  string     `gorm:"type:text"`
+	GroupID *uuid.UUID `gorm:"type:uuid;column:groupId"`
+
+	// Clave de deduplicación, única entre las notificaciones no eliminadas (ver handlers.DedupeConflict)
+	DedupeKey *string `gorm:"type:text;column:dedupeKey"`
+
+	// Trace ID de la petición que la creó; las entregas se registran con él para enlazarlas con su origen
+	TraceID *string `gorm:"type:varchar(32);column:traceId"`
+
+	// Estado de archivado y borrado lógico
+	Archived   bool           `gorm:"type:boolean;default:false"`
+	ArchivedAt *time.Time     `gorm:"type:timestamp;column:archivedAt"`
+	DeletedAt  gorm.DeletedAt `gorm:"index;column:deletedAt"`
 }
 
 func (Notification) TableName() string {
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Eventos del ciclo de vida de una notificación
+const (
+	NotificationEventCreated           = "created"
+	NotificationEventMerged            = "merged"
+	NotificationEventHeld              = "held"
+	NotificationEventDeliveryAttempted = "delivery_attempted"
+	NotificationEventDelivered         = "delivered"
+	NotificationEventSeen              = "seen"
+	NotificationEventRead              = "read"
+	NotificationEventArchived          = "archived"
+	NotificationEventRestored          = "restored"
+	NotificationEventDeleted           = "deleted"
+)
+
+// Resultados de un intento de entrega
+const (
+	DeliveryResultOK           = "ok"
+	DeliveryResultFailed       = "failed"
+	DeliveryResultNotConnected = "not_connected"
+	DeliveryResultSkipped      = "skipped"
+)
+
+// NotificationEvent es una entrada del historial de una notificación. La tabla es de solo
+// inserción (un trigger rechaza UPDATE y DELETE) y el id da el orden en que se registraron.
+type NotificationEvent struct {
+	ID             int64     `gorm:"primaryKey;autoIncrement"`
+	NotificationID uuid.UUID `gorm:"type:uuid;column:notificationId"`
+	UserID         uuid.UUID `gorm:"type:uuid;column:userId"`
+	Event          string    `gorm:"type:string"`
+	Channel        string    `gorm:"type:string"`
+	Result         string    `gorm:"type:string"`
+	Detail         string    `gorm:"type:text"`
+	CreatedAt      time.Time `gorm:"type:timestamp;column:createdAt"`
+}
+
+func (NotificationEvent) TableName() string {
+	return "NotificationEvents"
+}
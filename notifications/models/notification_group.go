@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxGroupActors es el máximo de actores recientes que se guardan por grupo
+const MaxGroupActors = 10
+
+// NotificationGroup agrupa notificaciones con el mismo (responsibleId, type, target)
+// dentro de una ventana de tiempo, ej. "Ana y 12 personas más dieron like a tu post"
+type NotificationGroup struct {
+	ID                   uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	ResponsibleID        uuid.UUID `gorm:"type:uuid;column:responsibleId"`
+	Type                 string    `gorm:"type:string"`
+	Target               string    `gorm:"type:text"`
+	ActorIDs             UUIDList  `gorm:"type:jsonb;column:actorIds"`
+	ActorCount           int       `gorm:"column:actorCount"`
+	Count                int       `gorm:"column:count"`
+	LatestNotificationID uuid.UUID `gorm:"type:uuid;column:latestNotificationId"`
+	FirstAt              time.Time `gorm:"type:timestamp;column:firstAt"`
+	LastAt               time.Time `gorm:"type:timestamp;column:lastAt"`
+}
+
+func (NotificationGroup) TableName() string {
+	return "NotificationGroups"
+}
+
+func (g *NotificationGroup) BeforeCreate(tx *gorm.DB) error {
+	if g.ID == uuid.Nil {
+		g.ID = uuid.New()
+	}
+	return nil
+}
+
+// AddActor coloca al actor al principio de la lista, sin duplicados y con tope MaxGroupActors
+func (g *NotificationGroup) AddActor(actorID uuid.UUID) {
+	actors := UUIDList{actorID}
+	for _, id := range g.ActorIDs {
+		if id != actorID && len(actors) < MaxGroupActors {
+			actors = append(actors, id)
+		}
+	}
+	g.ActorIDs = actors
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Estados de un evento del outbox
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusDone    = "done"
+	OutboxStatusFailed  = "failed"
+)
+
+// OutboxEvent es una entrega pendiente de una notificación por un canal. Se escribe en la
+// misma transacción que la notificación y el relay la publica después del commit.
+type OutboxEvent struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	NotificationID uuid.UUID  `gorm:"type:uuid;column:notificationId"`
+	Channel        string     `gorm:"type:string"`
+	Status         string     `gorm:"type:string"`
+	Attempts       int        `gorm:"type:integer"`
+	NextAttemptAt  time.Time  `gorm:"type:timestamp;column:nextAttemptAt"`
+	LastError      string     `gorm:"type:text;column:lastError"`
+	CreatedAt      time.Time  `gorm:"type:timestamp;column:createdAt"`
+	ProcessedAt    *time.Time `gorm:"type:timestamp;column:processedAt"`
+	// ReplicaID es la réplica a la que se encaminó el evento (la que tiene la conexión del usuario);
+	// nil si puede publicarlo cualquiera
+	ReplicaID *string `gorm:"type:text;column:replicaId"`
+}
+
+func (OutboxEvent) TableName() string {
+	return "OutboxEvents"
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Canales de entrega soportados
+const (
+	ChannelWebSocket = "websocket"
+	ChannelEmail     = "email"
+	ChannelPush      = "push"
+)
+
+// DefaultPreferenceType es el tipo comodín que aplica a los tipos sin preferencia propia
+const DefaultPreferenceType = "*"
+
+// NotificationPreference es la preferencia de un usuario para un tipo de notificación
+type NotificationPreference struct {
+	UserID          uuid.UUID  `gorm:"type:uuid;primaryKey;column:userId"`
+	Type            string     `gorm:"type:string;primaryKey"`
+	Enabled         bool       `gorm:"type:boolean"`
+	Channels        StringList `gorm:"type:jsonb"`
+	QuietHoursStart string     `gorm:"type:string;column:quietHoursStart"` // "HH:MM", vacío = sin horario de silencio
+	QuietHoursEnd   string     `gorm:"type:string;column:quietHoursEnd"`
+	Timezone        string     `gorm:"type:string"`
+	UpdatedAt       time.Time  `gorm:"type:timestamp;column:updatedAt"`
+}
+
+func (NotificationPreference) TableName() string {
+	return "NotificationPreferences"
+}
+
+// DefaultChannels son los canales de un usuario sin preferencias o de una preferencia sin "channels".
+// Incluye push: registrar un dispositivo ya es la señal de que el usuario lo quiere, y sin dispositivos
+// el canal no envía nada.
+func DefaultChannels() StringList {
+	return StringList{ChannelWebSocket, ChannelPush}
+}
+
+// DefaultPreference es la preferencia efectiva cuando el usuario no configuró nada
+func DefaultPreference(userID uuid.UUID, notificationType string) NotificationPreference {
+	return NotificationPreference{
+		UserID:   userID,
+		Type:     notificationType,
+		Enabled:  true,
+		Channels: DefaultChannels(),
+		Timezone: "UTC",
+	}
+}
+
+// HasChannel indica si la preferencia permite entregar por el canal dado
+func (p NotificationPreference) HasChannel(channel string) bool {
+	for _, c := range p.Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Location devuelve la zona horaria del usuario (UTC si no es válida)
+func (p NotificationPreference) Location() *time.Location {
+	if p.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// InQuietHours indica si t cae dentro del horario de silencio en la zona del usuario.
+// Soporta ventanas que cruzan la medianoche (ej. 22:00-07:00).
+func (p NotificationPreference) InQuietHours(t time.Time) bool {
+	start, end, ok := p.quietWindow()
+	if !ok {
+		return false
+	}
+	local := t.In(p.Location())
+	minute := local.Hour()*60 + local.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// QuietHoursEndAfter devuelve el próximo fin del horario de silencio posterior a t
+func (p NotificationPreference) QuietHoursEndAfter(t time.Time) time.Time {
+	_, end, ok := p.quietWindow()
+	if !ok {
+		return t
+	}
+	local := t.In(p.Location())
+	next := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, local.Location())
+	if !next.After(local) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next.UTC()
+}
+
+func (p NotificationPreference) quietWindow() (int, int, bool) {
+	if p.QuietHoursStart == "" || p.QuietHoursEnd == "" {
+		return 0, 0, false
+	}
+	start, err := ParseClock(p.QuietHoursStart)
+	if err != nil {
+		return 0, 0, false
+	}
+	end, err := ParseClock(p.QuietHoursEnd)
+	if err != nil || start == end {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// ParseClock convierte "HH:MM" en minutos desde medianoche
+func ParseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Tipos de regla de silencio
+const (
+	MuteActor  = "actor"
+	MuteTarget = "target"
+)
+
+// MuteRule silencia las notificaciones de un actor o sobre un target concreto
+type MuteRule struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UserID    uuid.UUID  `gorm:"type:uuid;column:userId;index"`
+	Kind      string     `gorm:"type:string"`
+	Value     string     `gorm:"type:text"`
+	ExpiresAt *time.Time `gorm:"type:timestamp;column:expiresAt"`
+	CreatedAt time.Time  `gorm:"type:timestamp;column:createdAt"`
+}
+
+func (MuteRule) TableName() string {
+	return "MuteRules"
+}
+
+// Matches indica si la regla silencia la notificación en el instante now
+func (m MuteRule) Matches(n Notification, now time.Time) bool {
+	if m.ExpiresAt != nil && !m.ExpiresAt.After(now) {
+		return false
+	}
+	switch m.Kind {
+	case MuteActor:
+		// Se parsea para que las reglas guardadas sin forma canónica (mayúsculas, llaves) también valgan
+		actorID, err := uuid.Parse(m.Value)
+		return err == nil && actorID == n.ActorID
+	case MuteTarget:
+		return m.Value == n.Target
+	}
+	return false
+}
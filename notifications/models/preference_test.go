@@ -0,0 +1,133 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/google/uuid"
+)
+
+func TestQuietHours(t *testing.T) {
+	cases := []struct {
+		name     string
+		pref     NotificationPreference
+		at       string
+		quiet    bool
+		endAfter string
+	}{
+		{
+			name:     "same day window",
+			pref:     NotificationPreference{QuietHoursStart: "13:00", QuietHoursEnd: "15:00", Timezone: "UTC"},
+			at:       "2024-01-15T14:00:00Z",
+			quiet:    true,
+			endAfter: "2024-01-15T15:00:00Z",
+		},
+		{
+			name:  "same day window, end is exclusive",
+			pref:  NotificationPreference{QuietHoursStart: "13:00", QuietHoursEnd: "15:00", Timezone: "UTC"},
+			at:    "2024-01-15T15:00:00Z",
+			quiet: false,
+		},
+		{
+			name:     "crosses midnight, before midnight",
+			pref:     NotificationPreference{QuietHoursStart: "22:00", QuietHoursEnd: "07:00", Timezone: "Europe/Madrid"},
+			at:       "2024-01-15T22:30:00Z", // 23:30 en Madrid
+			quiet:    true,
+			endAfter: "2024-01-16T06:00:00Z", // 07:00 del día siguiente
+		},
+		{
+			name:     "crosses midnight, after midnight",
+			pref:     NotificationPreference{QuietHoursStart: "22:00", QuietHoursEnd: "07:00", Timezone: "Europe/Madrid"},
+			at:       "2024-01-16T02:00:00Z", // 03:00 en Madrid
+			quiet:    true,
+			endAfter: "2024-01-16T06:00:00Z", // 07:00 del mismo día
+		},
+		{
+			name:  "crosses midnight, outside",
+			pref:  NotificationPreference{QuietHoursStart: "22:00", QuietHoursEnd: "07:00", Timezone: "Europe/Madrid"},
+			at:    "2024-01-16T12:00:00Z",
+			quiet: false,
+		},
+		{
+			// Nueva York pasa a EDT el 10 de marzo de 2024 a las 02:00: la noche dura una hora menos
+			name:     "DST spring forward",
+			pref:     NotificationPreference{QuietHoursStart: "22:00", QuietHoursEnd: "07:00", Timezone: "America/New_York"},
+			at:       "2024-03-10T04:00:00Z", // 23:00 EST del 9
+			quiet:    true,
+			endAfter: "2024-03-10T11:00:00Z", // 07:00 EDT, no 12:00Z
+		},
+		{
+			// Y vuelve a EST el 3 de noviembre de 2024: la noche dura una hora más
+			name:     "DST fall back",
+			pref:     NotificationPreference{QuietHoursStart: "22:00", QuietHoursEnd: "07:00", Timezone: "America/New_York"},
+			at:       "2024-11-03T03:00:00Z", // 23:00 EDT del 2
+			quiet:    true,
+			endAfter: "2024-11-03T12:00:00Z", // 07:00 EST, no 11:00Z
+		},
+		{
+			// 01:30 ocurre dos veces la noche del cambio; ambas están en silencio
+			name:     "DST repeated hour",
+			pref:     NotificationPreference{QuietHoursStart: "22:00", QuietHoursEnd: "07:00", Timezone: "America/New_York"},
+			at:       "2024-11-03T06:30:00Z", // 01:30 EST (la segunda)
+			quiet:    true,
+			endAfter: "2024-11-03T12:00:00Z",
+		},
+		{
+			name:  "no window",
+			pref:  NotificationPreference{Timezone: "UTC"},
+			at:    "2024-01-15T03:00:00Z",
+			quiet: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			at, _ := time.Parse(time.RFC3339, tc.at)
+			if got := tc.pref.InQuietHours(at); got != tc.quiet {
+				t.Fatalf("InQuietHours(%s) = %v, want %v", tc.at, got, tc.quiet)
+			}
+			if !tc.quiet {
+				return
+			}
+			want, _ := time.Parse(time.RFC3339, tc.endAfter)
+			if got := tc.pref.QuietHoursEndAfter(at); !got.Equal(want) {
+				t.Errorf("QuietHoursEndAfter(%s) = %s, want %s", tc.at, got.Format(time.RFC3339), tc.endAfter)
+			}
+		})
+	}
+}
+
+func TestMuteRuleMatches(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+	actor := uuid.New()
+	noti := Notification{ActorID: actor, Target: "post-1"}
+
+	cases := []struct {
+		name  string
+		rule  MuteRule
+		match bool
+	}{
+		{"actor", MuteRule{Kind: MuteActor, Value: actor.String()}, true},
+		{"actor in upper case", MuteRule{Kind: MuteActor, Value: strings.ToUpper(actor.String())}, true},
+		{"actor with braces", MuteRule{Kind: MuteActor, Value: "{" + actor.String() + "}"}, true},
+		{"other actor", MuteRule{Kind: MuteActor, Value: uuid.NewString()}, false},
+		{"invalid actor", MuteRule{Kind: MuteActor, Value: "not-a-uuid"}, false},
+		{"target", MuteRule{Kind: MuteTarget, Value: "post-1"}, true},
+		{"other target", MuteRule{Kind: MuteTarget, Value: "post-2"}, false},
+		{"target does not match actor", MuteRule{Kind: MuteTarget, Value: actor.String()}, false},
+		{"unknown kind", MuteRule{Kind: "type", Value: "post-1"}, false},
+		{"not expired", MuteRule{Kind: MuteTarget, Value: "post-1", ExpiresAt: &future}, true},
+		{"expired", MuteRule{Kind: MuteTarget, Value: "post-1", ExpiresAt: &past}, false},
+		{"expires now", MuteRule{Kind: MuteTarget, Value: "post-1", ExpiresAt: &now}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.rule.Matches(noti, now); got != tc.match {
+				t.Errorf("Matches = %v, want %v", got, tc.match)
+			}
+		})
+	}
+}
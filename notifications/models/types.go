@@ -0,0 +1,78 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// UUIDList es una lista de UUIDs almacenada como JSON en la base de datos
+type UUIDList []uuid.UUID
+
+func (l UUIDList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (l *UUIDList) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for UUIDList: %T", value)
+	}
+	return json.Unmarshal(data, l)
+}
+
+// Strings devuelve los UUIDs como strings para serializarlos en respuestas
+func (l UUIDList) Strings() []string {
+	out := make([]string, 0, len(l))
+	for _, id := range l {
+		out = append(out, id.String())
+	}
+	return out
+}
+
+// StringList es una lista de strings almacenada como JSON en la base de datos
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (l *StringList) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for StringList: %T", value)
+	}
+	return json.Unmarshal(data, l)
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserContact guarda los datos de contacto del usuario para los canales fuera de línea
+type UserContact struct {
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey;column:userId"`
+	Email     string    `gorm:"type:text"`
+	UpdatedAt time.Time `gorm:"type:timestamp;column:updatedAt"`
+}
+
+func (UserContact) TableName() string {
+	return "UserContacts"
+}
+
+// UserPresence registra si el usuario tiene un WebSocket abierto y cuándo se le vio por última vez.
+// Se guarda en Postgres para que todas las réplicas vean la misma presencia.
+type UserPresence struct {
+	UserID     uuid.UUID `gorm:"type:uuid;primaryKey;column:userId"`
+	Connected  bool      `gorm:"type:boolean"`
+	LastSeenAt time.Time `gorm:"type:timestamp;column:lastSeenAt"`
+	// ReplicaID es la réplica que tiene abierta la conexión; el outbox le encamina las entregas
+	ReplicaID *string `gorm:"type:text;column:replicaId"`
+}
+
+func (UserPresence) TableName() string {
+	return "UserPresence"
+}
+
+// Estados de una entrega por email
+const (
+	EmailStatusSending     = "sending"
+	EmailStatusSent        = "sent"
+	EmailStatusFailed      = "failed"
+	EmailStatusRateLimited = "rate_limited"
+	// EmailStatusSkipped marca las notificaciones que no se envían por las preferencias del usuario,
+	// para que el worker no las vuelva a seleccionar
+	EmailStatusSkipped = "skipped"
+)
+
+// EmailDelivery registra el envío por email de una notificación (una fila por notificación)
+type EmailDelivery struct {
+	NotificationID uuid.UUID  `gorm:"type:uuid;primaryKey;column:notificationId"`
+	UserID         uuid.UUID  `gorm:"type:uuid;column:userId"`
+	Status         string     `gorm:"type:string"`
+	Attempts       int        `gorm:"type:integer"`
+	LastError      string     `gorm:"type:text;column:lastError"`
+	CreatedAt      time.Time  `gorm:"type:timestamp;column:createdAt"`
+	SentAt         *time.Time `gorm:"type:timestamp;column:sentAt"`
+	// ClaimedAt es cuándo una réplica reclamó el envío; hace de token del lease mientras está en sending
+	ClaimedAt *time.Time `gorm:"type:timestamp;column:claimedAt"`
+}
+
+func (EmailDelivery) TableName() string {
+	return "EmailDeliveries"
+}
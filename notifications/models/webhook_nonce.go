@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// WebhookNonce registra una firma de webhook ya aceptada para rechazar reenvíos
+type WebhookNonce struct {
+	Source    string    `gorm:"type:string;primaryKey"`
+	Nonce     string    `gorm:"type:string;primaryKey"`
+	ExpiresAt time.Time `gorm:"type:timestamp;column:expiresAt"`
+}
+
+func (WebhookNonce) TableName() string {
+	return "WebhookNonces"
+}
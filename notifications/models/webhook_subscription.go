@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookSubscription es un endpoint de terceros que recibe las notificaciones de un usuario
+type WebhookSubscription struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	UserID     uuid.UUID  `gorm:"type:uuid;column:userId;index"`
+	URL        string     `gorm:"type:text;column:url"`
+	EventTypes StringList `gorm:"type:jsonb;column:eventTypes"` // tipos de notificación; vacío o "*" = todos
+	Secret     string     `gorm:"type:text"`
+	Active     bool       `gorm:"type:boolean"`
+	CreatedAt  time.Time  `gorm:"type:timestamp;column:createdAt"`
+}
+
+func (WebhookSubscription) TableName() string {
+	return "WebhookSubscriptions"
+}
+
+// Accepts indica si la suscripción quiere recibir notificaciones del tipo dado
+func (s WebhookSubscription) Accepts(notificationType string) bool {
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == "*" || t == notificationType {
+			return true
+		}
+	}
+	return false
+}
+
+// Estados de una entrega de webhook
+const (
+	WebhookStatusPending    = "pending"
+	WebhookStatusSucceeded  = "succeeded"
+	WebhookStatusDeadLetter = "dead_letter"
+)
+
+// WebhookDelivery es el registro de entrega de un evento a una suscripción
+type WebhookDelivery struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	SubscriptionID uuid.UUID  `gorm:"type:uuid;column:subscriptionId"`
+	NotificationID uuid.UUID  `gorm:"type:uuid;column:notificationId"`
+	Event          string     `gorm:"type:string"`
+	Payload        string     `gorm:"type:jsonb"`
+	Status         string     `gorm:"type:string"`
+	Attempts       int        `gorm:"type:integer"`
+	NextAttemptAt  time.Time  `gorm:"type:timestamp;column:nextAttemptAt"`
+	LastStatusCode int        `gorm:"type:integer;column:lastStatusCode"`
+	LastError      string     `gorm:"type:text;column:lastError"`
+	CreatedAt      time.Time  `gorm:"type:timestamp;column:createdAt"`
+	DeliveredAt    *time.Time `gorm:"type:timestamp;column:deliveredAt"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "WebhookDeliveries"
+}
@@ -0,0 +1,81 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+// wakeChannel es el canal de LISTEN/NOTIFY por el que se avisa a una réplica de que tiene eventos
+// encaminados; el payload es el id de la réplica
+const wakeChannel = "outbox_wake"
+
+// wake avisa a la réplica de que tiene un evento encaminado. Si el aviso se pierde lo publicará igualmente
+// en su siguiente vuelta.
+func wake(ctx context.Context, db *gorm.DB, replica string) {
+	if err := db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", wakeChannel, replica).Error; err != nil {
+		slog.Warn("Could not notify replica of routed outbox event", "replica", replica, "error", err)
+	}
+}
+
+// Listen escucha los avisos dirigidos a replica y devuelve un canal para Relay.Wake. Ocupa una
+// conexión del pool mientras ctx siga vivo y, si se cae, vuelve a escuchar con backoff.
+func Listen(ctx context.Context, db *gorm.DB, replica string) <-chan struct{} {
+	woken := make(chan struct{}, 1)
+	go func() {
+		backoff := time.Second
+		for {
+			err := listen(ctx, db, replica, woken)
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("Outbox wake listener stopped, retrying", "error", err, "backoff", backoff)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, time.Minute)
+		}
+	}()
+	return woken
+}
+
+func listen(ctx context.Context, db *gorm.DB, replica string, woken chan<- struct{}) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		pgConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return errors.New("database driver does not support LISTEN")
+		}
+		if _, err := pgConn.Conn().Exec(ctx, "LISTEN "+wakeChannel); err != nil {
+			return err
+		}
+		for {
+			notification, err := pgConn.Conn().WaitForNotification(ctx)
+			if err != nil {
+				return err
+			}
+			if notification.Payload != replica {
+				continue
+			}
+			select {
+			case woken <- struct{}{}:
+			default: // ya hay una vuelta pendiente
+			}
+		}
+	})
+}
@@ -0,0 +1,329 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"notifications/clock"
+	"notifications/health"
+	"notifications/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Publisher entrega una notificación por un canal concreto (websocket, push, ...)
+type Publisher interface {
+	Publish(ctx context.Context, noti models.Notification) error
+}
+
+// RouteError lo devuelve un Publisher cuando el evento debe publicarlo otra réplica, la que tiene la
+// conexión del usuario. El relay no lo cuenta como intento: lo encamina a esa réplica y la avisa.
+type RouteError struct {
+	Replica string
+}
+
+func (e *RouteError) Error() string {
+	return fmt.Sprintf("event must be published by replica %s", e.Replica)
+}
+
+// PublisherFunc adapta una función a Publisher
+type PublisherFunc func(ctx context.Context, noti models.Notification) error
+
+func (f PublisherFunc) Publish(ctx context.Context, noti models.Notification) error {
+	return f(ctx, noti)
+}
+
+// Enqueue escribe un evento pendiente por canal. Se llama dentro de la transacción de creación:
+// si la transacción se revierte no queda nada que publicar y si se confirma el relay lo publicará
+// aunque el proceso muera justo después del commit.
+func Enqueue(tx *gorm.DB, noti models.Notification, channels []string) ([]uuid.UUID, error) {
+	if len(channels) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	events := make([]models.OutboxEvent, 0, len(channels))
+	ids := make([]uuid.UUID, 0, len(channels))
+	for _, channel := range channels {
+		event := models.OutboxEvent{
+			ID:             uuid.New(),
+			NotificationID: noti.ID,
+			Channel:        channel,
+			Status:         models.OutboxStatusPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+		events = append(events, event)
+		ids = append(ids, event.ID)
+	}
+	if err := tx.Create(&events).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Relay publica los eventos pendientes del outbox en su canal y los marca como procesados.
+//
+// La entrega es al menos una vez: los eventos se reclaman en una transacción corta que los aparta
+// durante Lease, se publican fuera de cualquier transacción y cada resultado se guarda en su propia
+// actualización. Si el proceso muere entre publicar y guardar, o una publicación tarda más que el
+// lease, el evento se vuelve a publicar; los clientes deduplican por id de notificación.
+//
+// Un evento encaminado con RouteError solo lo reclama la réplica indicada (Replica) hasta que pasa
+// RouteTimeout; después cualquiera puede tomarlo y, si vuelve a encaminarse a la misma réplica (que no
+// lo publicó a tiempo, probablemente porque murió), se da por hecho.
+type Relay struct {
+	DB          *gorm.DB
+	Publishers  map[string]Publisher // por canal
+	Clock       clock.Clock
+	Interval    time.Duration
+	MaxAttempts int
+	Backoff     time.Duration
+	BatchSize   int
+	// Lease es cuánto se aparta un evento reclamado antes de que otra réplica pueda tomarlo;
+	// por defecto defaultLease
+	Lease time.Duration
+	// Retention es cuánto se conservan los eventos procesados; 0 los conserva siempre
+	Retention time.Duration
+	// Heartbeat late en cada vuelta para /readyz; opcional
+	Heartbeat *health.Heartbeat
+	// Replica identifica a esta réplica en los eventos encaminados
+	Replica string
+	// RouteTimeout es cuánto espera un evento encaminado a que lo publique su réplica; por defecto
+	// defaultRouteTimeout
+	RouteTimeout time.Duration
+	// Wake adelanta la siguiente vuelta (ver Listen); opcional
+	Wake <-chan struct{}
+}
+
+// Run publica eventos vencidos en cada tick hasta que ctx se cancele.
+// Al arrancar recupera lo que quedó pendiente si el proceso murió tras un commit.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := r.Clock.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := r.RunOnce(ctx)
+		if err != nil {
+			slog.Error("Outbox relay error", "error", err)
+		}
+		r.Heartbeat.Beat(err)
+		if err := r.purge(ctx); err != nil {
+			slog.Error("Outbox purge error", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		case <-r.Wake:
+		}
+	}
+}
+
+// RunOnce publica un lote de eventos vencidos y devuelve cuántos se publicaron
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	return r.process(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where(`status = ? AND "nextAttemptAt" <= ?`, models.OutboxStatusPending, r.Clock.Now())
+	})
+}
+
+// Pending cuenta los eventos sin publicar, vencidos o no; alimenta la métrica queue_depth
+func (r *Relay) Pending(ctx context.Context) (int64, error) {
+	var n int64
+	err := r.DB.WithContext(ctx).Model(&models.OutboxEvent{}).
+		Where("status = ?", models.OutboxStatusPending).
+		Count(&n).Error
+	return n, err
+}
+
+// Flush publica de inmediato los eventos dados; se llama tras el commit para no esperar al
+// siguiente tick. Los que otra réplica ya reclamó (lease vigente) o procesó se ignoran.
+func (r *Relay) Flush(ctx context.Context, ids []uuid.UUID) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return r.process(ctx, func(db *gorm.DB) *gorm.DB {
+		return db.Where(`id IN ? AND status = ? AND "nextAttemptAt" <= ?`, ids, models.OutboxStatusPending, r.Clock.Now())
+	})
+}
+
+// defaultLease cubre una publicación push con sus reintentos por dispositivo
+const defaultLease = 2 * time.Minute
+
+func (r *Relay) lease() time.Duration {
+	if r.Lease > 0 {
+		return r.Lease
+	}
+	return defaultLease
+}
+
+// defaultRouteTimeout deja varias vueltas a la réplica destino antes de que otra tome el evento
+const defaultRouteTimeout = 30 * time.Second
+
+func (r *Relay) routeTimeout() time.Duration {
+	if r.RouteTimeout > 0 {
+		return r.RouteTimeout
+	}
+	return defaultRouteTimeout
+}
+
+// claimableScope deja fuera los eventos encaminados a otra réplica mientras no venza RouteTimeout
+func (r *Relay) claimableScope(db *gorm.DB) *gorm.DB {
+	return db.Where(`("replicaId" IS NULL OR "replicaId" = ? OR "nextAttemptAt" <= ?)`,
+		r.Replica, r.Clock.Now().Add(-r.routeTimeout()))
+}
+
+// leaseUntil se trunca a microsegundos, la precisión de Postgres, para poder compararlo después
+func (r *Relay) leaseUntil() time.Time {
+	return r.Clock.Now().Add(r.lease()).Truncate(time.Microsecond)
+}
+
+func (r *Relay) process(ctx context.Context, scope func(*gorm.DB) *gorm.DB) (int, error) {
+	events, err := r.claim(ctx, scope)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, event := range events {
+		if ok, err := r.renew(ctx, &event); !ok {
+			if err != nil {
+				slog.Error("Could not renew outbox event lease", "event_id", event.ID, "error", err)
+			}
+			continue
+		}
+		err := r.publish(ctx, event)
+		r.finish(ctx, event, err)
+		if err == nil {
+			published++
+		}
+	}
+	return published, nil
+}
+
+// claim reclama un lote con FOR UPDATE SKIP LOCKED y lo aparta moviendo "nextAttemptAt" al final del
+// lease. El valor de "nextAttemptAt" hace de token del lease.
+func (r *Relay) claim(ctx context.Context, scope func(*gorm.DB) *gorm.DB) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Scopes(scope, r.claimableScope).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Order(`"createdAt", channel`).
+			Limit(r.BatchSize).
+			Find(&events).Error; err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, 0, len(events))
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+		leaseUntil := r.leaseUntil()
+		if err := tx.Model(&models.OutboxEvent{}).Where("id IN ?", ids).
+			Update("nextAttemptAt", leaseUntil).Error; err != nil {
+			return err
+		}
+		for i := range events {
+			events[i].NextAttemptAt = leaseUntil
+		}
+		return nil
+	})
+	return events, err
+}
+
+// renew extiende el lease justo antes de publicar. Devuelve false si el lease expiró y otra réplica
+// tomó el evento.
+func (r *Relay) renew(ctx context.Context, event *models.OutboxEvent) (bool, error) {
+	leaseUntil := r.leaseUntil()
+	result := r.DB.WithContext(ctx).Model(&models.OutboxEvent{}).
+		Where(`id = ? AND status = ? AND "nextAttemptAt" = ?`, event.ID, models.OutboxStatusPending, event.NextAttemptAt).
+		Update("nextAttemptAt", leaseUntil)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	event.NextAttemptAt = leaseUntil
+	return true, nil
+}
+
+// errNotificationGone indica que la notificación se eliminó antes de publicarse
+var errNotificationGone = errors.New("notification no longer exists")
+
+func (r *Relay) publish(ctx context.Context, event models.OutboxEvent) error {
+	publisher, ok := r.Publishers[event.Channel]
+	if !ok {
+		return fmt.Errorf("no publisher for channel %q", event.Channel)
+	}
+
+	var noti models.Notification
+	if err := r.DB.WithContext(ctx).First(&noti, "id = ?", event.NotificationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errNotificationGone
+		}
+		return err
+	}
+	return publisher.Publish(ctx, noti)
+}
+
+// finish marca el evento como hecho, lo reprograma con backoff o lo da por fallido, en una
+// actualización corta condicionada al lease
+func (r *Relay) finish(ctx context.Context, event models.OutboxEvent, publishErr error) {
+	now := r.Clock.Now()
+	updates := map[string]interface{}{"attempts": event.Attempts + 1}
+
+	var route *RouteError
+	switch {
+	case errors.As(publishErr, &route) && (event.ReplicaID == nil || *event.ReplicaID != route.Replica):
+		// No cuenta como intento: la réplica destino lo reclama enseguida
+		updates = map[string]interface{}{"replicaId": route.Replica, "nextAttemptAt": now}
+		slog.Debug("Outbox event routed to another replica", "event_id", event.ID, "channel", event.Channel, "replica", route.Replica)
+	case route != nil:
+		// Ya se encaminó a esa réplica y no lo publicó a tiempo: se da por hecho como si el usuario no
+		// estuviera conectado (lo recibirá como pendiente al conectarse)
+		updates["status"] = models.OutboxStatusDone
+		updates["processedAt"] = now
+		updates["lastError"] = publishErr.Error()
+		slog.Warn("Routed outbox event was not published by its replica", "event_id", event.ID, "replica", route.Replica)
+	case publishErr == nil, errors.Is(publishErr, errNotificationGone):
+		updates["status"] = models.OutboxStatusDone
+		updates["processedAt"] = now
+	case event.Attempts+1 >= r.MaxAttempts:
+		updates["status"] = models.OutboxStatusFailed
+		updates["processedAt"] = now
+		updates["lastError"] = publishErr.Error()
+		slog.Error("Outbox event failed permanently", "event_id", event.ID, "channel", event.Channel, "attempts", event.Attempts+1, "error", publishErr)
+	default:
+		updates["lastError"] = publishErr.Error()
+		updates["nextAttemptAt"] = now.Add(r.Backoff * time.Duration(1<<min(event.Attempts, 10)))
+		slog.Warn("Outbox event will be retried", "event_id", event.ID, "channel", event.Channel, "error", publishErr)
+	}
+
+	// Si el lease expiró durante la publicación otra réplica puede tenerlo; su resultado prevalece
+	result := r.DB.WithContext(ctx).Model(&models.OutboxEvent{}).
+		Where(`id = ? AND "nextAttemptAt" = ?`, event.ID, event.NextAttemptAt).
+		Updates(updates)
+	if result.Error != nil {
+		slog.Error("Could not update outbox event", "event_id", event.ID, "error", result.Error)
+	} else if result.RowsAffected == 0 {
+		slog.Warn("Outbox event lease expired before recording the result", "event_id", event.ID)
+	} else if _, routed := updates["replicaId"]; routed {
+		wake(ctx, r.DB, route.Replica)
+	}
+}
+
+// purge elimina los eventos procesados más antiguos que Retention
+func (r *Relay) purge(ctx context.Context) error {
+	if r.Retention <= 0 {
+		return nil
+	}
+	return r.DB.WithContext(ctx).
+		Where(`status <> ? AND "processedAt" < ?`, models.OutboxStatusPending, r.Clock.Now().Add(-r.Retention)).
+		Delete(&models.OutboxEvent{}).Error
+}
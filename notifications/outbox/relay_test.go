@@ -0,0 +1,206 @@
+package outbox
+
+import (
+	"context"
+	"notifications/clock"
+	"notifications/internal/testdb"
+	"notifications/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func enqueueNotification(t *testing.T, db *gorm.DB) models.OutboxEvent {
+	t.Helper()
+	noti := models.Notification{ActorID: uuid.New(), RecipientID: uuid.New(), ResponsibleID: uuid.New(), Type: "like", Content: "outbox"}
+	if err := db.Create(&noti).Error; err != nil {
+		t.Fatal(err)
+	}
+	ids, err := Enqueue(db, noti, []string{models.ChannelWebSocket})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event models.OutboxEvent
+	if err := db.First(&event, "id = ?", ids[0]).Error; err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestRelayPublishesOutsideTheClaimTransaction(t *testing.T) {
+	db := testdb.Open(t)
+	event := enqueueNotification(t, db)
+	fake := clock.NewFake(time.Now().Add(time.Second))
+
+	var lockErr error
+	relay := &Relay{
+		DB:          db,
+		Clock:       fake,
+		MaxAttempts: 3,
+		Backoff:     time.Second,
+		BatchSize:   10,
+		Publishers: map[string]Publisher{models.ChannelWebSocket: PublisherFunc(func(ctx context.Context, noti models.Notification) error {
+			// Si la publicación corriera dentro de la transacción del lote, la fila seguiría bloqueada
+			lockErr = db.Transaction(func(tx *gorm.DB) error {
+				return tx.Exec(`SELECT id FROM "OutboxEvents" WHERE id = ? FOR UPDATE NOWAIT`, event.ID).Error
+			})
+			return nil
+		})},
+	}
+
+	published, err := relay.RunOnce(context.Background())
+	if err != nil || published != 1 {
+		t.Fatalf("RunOnce = %d, %v; want 1 published", published, err)
+	}
+	if lockErr != nil {
+		t.Fatalf("event row was locked while publishing: %v", lockErr)
+	}
+	if err := db.First(&event, "id = ?", event.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != models.OutboxStatusDone || event.Attempts != 1 {
+		t.Errorf("event = %s after %d attempts, want done after 1", event.Status, event.Attempts)
+	}
+}
+
+func TestRelayLeaseIsAtLeastOnce(t *testing.T) {
+	db := testdb.Open(t)
+	event := enqueueNotification(t, db)
+	fake := clock.NewFake(time.Now().Add(time.Second))
+
+	published := map[string]int{}
+	newRelay := func(name string) *Relay {
+		return &Relay{
+			DB:          db,
+			Clock:       fake,
+			MaxAttempts: 3,
+			Backoff:     time.Second,
+			BatchSize:   10,
+			Lease:       time.Minute,
+			Publishers: map[string]Publisher{models.ChannelWebSocket: PublisherFunc(func(context.Context, models.Notification) error {
+				published[name]++
+				return nil
+			})},
+		}
+	}
+	crashed, replica := newRelay("crashed"), newRelay("replica")
+
+	// Una réplica reclama el lote y muere antes de publicar
+	if _, err := crashed.claim(context.Background(), func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("id = ?", event.ID)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mientras dura el lease nadie más lo toma, tampoco un Flush
+	if n, _ := replica.RunOnce(context.Background()); n != 0 {
+		t.Fatalf("replica published a leased event")
+	}
+	if n, _ := replica.Flush(context.Background(), []uuid.UUID{event.ID}); n != 0 {
+		t.Fatalf("flush published a leased event")
+	}
+
+	// Al expirar el lease otra réplica lo publica
+	fake.Advance(time.Minute + time.Second)
+	if n, err := replica.RunOnce(context.Background()); err != nil || n != 1 {
+		t.Fatalf("RunOnce after lease expiry = %d, %v; want 1", n, err)
+	}
+	if published["replica"] != 1 || published["crashed"] != 0 {
+		t.Errorf("published = %v, want only the replica", published)
+	}
+}
+
+func TestRelayRoutesEventsToTheOwningReplica(t *testing.T) {
+	db := testdb.Open(t)
+	event := enqueueNotification(t, db)
+	fake := clock.NewFake(time.Now().Add(time.Second))
+
+	published := map[string]int{}
+	// owner es la réplica con la conexión del usuario; las demás encaminan el evento hacia ella
+	newRelay := func(name string) *Relay {
+		return &Relay{
+			DB:           db,
+			Clock:        fake,
+			MaxAttempts:  3,
+			Backoff:      time.Second,
+			BatchSize:    10,
+			Replica:      name,
+			RouteTimeout: time.Minute,
+			Publishers: map[string]Publisher{models.ChannelWebSocket: PublisherFunc(func(context.Context, models.Notification) error {
+				if name != "owner" {
+					return &RouteError{Replica: "owner"}
+				}
+				published[name]++
+				return nil
+			})},
+		}
+	}
+	other, owner, third := newRelay("other"), newRelay("owner"), newRelay("third")
+	load := func() models.OutboxEvent {
+		t.Helper()
+		var current models.OutboxEvent
+		if err := db.First(&current, "id = ?", event.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return current
+	}
+
+	// Encaminar no cuenta como intento ni confirma el evento
+	if n, err := other.RunOnce(context.Background()); err != nil || n != 0 {
+		t.Fatalf("RunOnce on a replica without the connection = %d, %v; want 0", n, err)
+	}
+	routed := load()
+	if routed.Status != models.OutboxStatusPending || routed.Attempts != 0 ||
+		routed.ReplicaID == nil || *routed.ReplicaID != "owner" {
+		t.Fatalf("routed event = %s, %d attempts, replica %v; want pending for owner", routed.Status, routed.Attempts, routed.ReplicaID)
+	}
+
+	// Solo la réplica destino lo reclama y lo confirma
+	if n, _ := third.RunOnce(context.Background()); n != 0 {
+		t.Fatal("another replica claimed an event routed to owner")
+	}
+	if n, err := owner.RunOnce(context.Background()); err != nil || n != 1 {
+		t.Fatalf("owner RunOnce = %d, %v; want 1", n, err)
+	}
+	if done := load(); done.Status != models.OutboxStatusDone || published["owner"] != 1 {
+		t.Errorf("event = %s, owner published %d; want done once", done.Status, published["owner"])
+	}
+}
+
+func TestRelayRoutedEventOutlivesADeadReplica(t *testing.T) {
+	db := testdb.Open(t)
+	event := enqueueNotification(t, db)
+	fake := clock.NewFake(time.Now().Add(time.Second))
+
+	relay := &Relay{
+		DB:           db,
+		Clock:        fake,
+		MaxAttempts:  3,
+		Backoff:      time.Second,
+		BatchSize:    10,
+		Replica:      "alive",
+		RouteTimeout: time.Minute,
+		// La presencia sigue apuntando a una réplica que murió sin marcar la desconexión
+		Publishers: map[string]Publisher{models.ChannelWebSocket: PublisherFunc(func(context.Context, models.Notification) error {
+			return &RouteError{Replica: "dead"}
+		})},
+	}
+	if _, err := relay.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pasado RouteTimeout otra réplica lo toma y, como volvería a la misma réplica, lo da por hecho
+	fake.Advance(time.Minute)
+	if _, err := relay.RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var current models.OutboxEvent
+	if err := db.First(&current, "id = ?", event.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if current.Status != models.OutboxStatusDone {
+		t.Errorf("event routed to a dead replica is %s, want done", current.Status)
+	}
+}
@@ -0,0 +1,72 @@
+package preferences
+
+import (
+	"errors"
+	"notifications/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrSuppressed indica que la notificación no debe guardarse ni entregarse por decisión del usuario
+var ErrSuppressed = errors.New("notification suppressed by user preferences")
+
+// Resolve devuelve la preferencia efectiva de un usuario para un tipo:
+// la específica del tipo, la comodín "*" o la preferencia por defecto
+func Resolve(db *gorm.DB, userID uuid.UUID, notificationType string) (models.NotificationPreference, error) {
+	var prefs []models.NotificationPreference
+	if err := db.Where(`"userId" = ? AND type IN ?`, userID,
+		[]string{notificationType, models.DefaultPreferenceType}).
+		Find(&prefs).Error; err != nil {
+		return models.NotificationPreference{}, err
+	}
+
+	var fallback *models.NotificationPreference
+	for i := range prefs {
+		if prefs[i].Type == notificationType {
+			return prefs[i], nil
+		}
+		fallback = &prefs[i]
+	}
+	if fallback != nil {
+		return *fallback, nil
+	}
+	return models.DefaultPreference(userID, notificationType), nil
+}
+
+// IsMuted indica si alguna regla vigente del destinatario silencia la notificación
+func IsMuted(db *gorm.DB, noti models.Notification, now time.Time) (bool, error) {
+	var rules []models.MuteRule
+	if err := db.Where(`"userId" = ? AND ("expiresAt" IS NULL OR "expiresAt" > ?)`, noti.ResponsibleID, now).
+		Find(&rules).Error; err != nil {
+		return false, err
+	}
+	for _, rule := range rules {
+		if rule.Matches(noti, now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Check aplica preferencias y reglas de silencio a una notificación entrante.
+// Devuelve ErrSuppressed si el tipo está desactivado o la notificación está silenciada.
+func Check(db *gorm.DB, noti models.Notification) (models.NotificationPreference, error) {
+	pref, err := Resolve(db, noti.ResponsibleID, noti.Type)
+	if err != nil {
+		return pref, err
+	}
+	if !pref.Enabled {
+		return pref, ErrSuppressed
+	}
+
+	muted, err := IsMuted(db, noti, time.Now())
+	if err != nil {
+		return pref, err
+	}
+	if muted {
+		return pref, ErrSuppressed
+	}
+	return pref, nil
+}
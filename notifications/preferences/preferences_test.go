@@ -0,0 +1,61 @@
+package preferences
+
+import (
+	"errors"
+	"notifications/internal/testdb"
+	"notifications/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCheck(t *testing.T) {
+	db := testdb.Open(t)
+	user, mutedActor := uuid.New(), uuid.New()
+	expired := time.Now().Add(-time.Hour)
+
+	create := func(value interface{}) {
+		t.Helper()
+		if err := db.Create(value).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	create(&[]models.NotificationPreference{
+		{UserID: user, Type: models.DefaultPreferenceType, Enabled: true, Channels: models.StringList{models.ChannelWebSocket}, Timezone: "UTC", UpdatedAt: time.Now()},
+		{UserID: user, Type: "like", Enabled: false, Channels: models.StringList{models.ChannelWebSocket}, Timezone: "UTC", UpdatedAt: time.Now()},
+	})
+	create(&[]models.MuteRule{
+		{ID: uuid.New(), UserID: user, Kind: models.MuteActor, Value: mutedActor.String(), CreatedAt: time.Now()},
+		{ID: uuid.New(), UserID: user, Kind: models.MuteTarget, Value: "muted-post", CreatedAt: time.Now()},
+		{ID: uuid.New(), UserID: user, Kind: models.MuteTarget, Value: "expired-post", ExpiresAt: &expired, CreatedAt: time.Now()},
+	})
+
+	cases := []struct {
+		name       string
+		noti       models.Notification
+		suppressed bool
+		prefType   string
+	}{
+		{"wildcard preference", models.Notification{ResponsibleID: user, ActorID: uuid.New(), Type: "comment", Target: "post"}, false, models.DefaultPreferenceType},
+		{"type disabled", models.Notification{ResponsibleID: user, ActorID: uuid.New(), Type: "like", Target: "post"}, true, "like"},
+		{"muted actor", models.Notification{ResponsibleID: user, ActorID: mutedActor, Type: "comment", Target: "post"}, true, models.DefaultPreferenceType},
+		{"muted target", models.Notification{ResponsibleID: user, ActorID: uuid.New(), Type: "comment", Target: "muted-post"}, true, models.DefaultPreferenceType},
+		{"expired mute", models.Notification{ResponsibleID: user, ActorID: uuid.New(), Type: "comment", Target: "expired-post"}, false, models.DefaultPreferenceType},
+		{"other user's mutes do not apply", models.Notification{ResponsibleID: uuid.New(), ActorID: mutedActor, Type: "comment", Target: "muted-post"}, false, "comment"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pref, err := Check(db, tc.noti)
+			if tc.suppressed != errors.Is(err, ErrSuppressed) {
+				t.Fatalf("Check error = %v, suppressed want %v", err, tc.suppressed)
+			}
+			if err != nil && !errors.Is(err, ErrSuppressed) {
+				t.Fatal(err)
+			}
+			if pref.Type != tc.prefType {
+				t.Errorf("resolved preference type %q, want %q", pref.Type, tc.prefType)
+			}
+		})
+	}
+}
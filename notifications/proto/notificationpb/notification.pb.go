@@ -23,15 +23,16 @@ const (
 )
 
 type FollowCreatedRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	ActorId       string                 `protobuf:"bytes,1,opt,name=actorId,proto3" json:"actorId,omitempty"`
-	RecipeId      string                 `protobuf:"bytes,2,opt,name=recipeId,proto3" json:"recipeId,omitempty"`
-	ResponsibleId string                 `protobuf:"bytes,3,opt,name=responsableId,proto3" json:"responsableId,omitempty"`
-	Type          string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
-	Content       string                 `protobuf:"bytes,5,opt,name=content,proto3" json:"content,omitempty"`
-	Timestamp     string                 `protobuf:"bytes,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ActorId        string                 `protobuf:"bytes,1,opt,name=actorId,proto3" json:"actorId,omitempty"`
+	RecipeId       string                 `protobuf:"bytes,2,opt,name=recipeId,proto3" json:"recipeId,omitempty"`
+	ResponsibleId  string                 `protobuf:"bytes,3,opt,name=responsableId,proto3" json:"responsableId,omitempty"`
+	Type           string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
+	Content        string                 `protobuf:"bytes,5,opt,name=content,proto3" json:"content,omitempty"`
+	Timestamp      string                 `protobuf:"bytes,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	IdempotencyKey string                 `protobuf:"bytes,7,opt,name=idempotencyKey,proto3" json:"idempotencyKey,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *FollowCreatedRequest) Reset() {
@@ -106,6 +107,13 @@ func (x *FollowCreatedRequest) GetTimestamp() string {
 	return ""
 }
 
+func (x *FollowCreatedRequest) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
 type NotificationResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Message       string                 `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
@@ -154,14 +162,15 @@ var File_proto_notification_proto protoreflect.FileDescriptor
 
 const file_proto_notification_proto_rawDesc = "" +
 	"\n" +
-	"\x18proto/notification.proto\x12\fnotification\"\xbe\x01\n" +
+	"\x18proto/notification.proto\x12\fnotification\"\xe6\x01\n" +
 	"\x14FollowCreatedRequest\x12\x18\n" +
 	"\aactorId\x18\x01 \x01(\tR\aactorId\x12\x1a\n" +
 	"\brecipeId\x18\x02 \x01(\tR\brecipeId\x12$\n" +
 	"\rresponsableId\x18\x03 \x01(\tR\rresponsableId\x12\x12\n" +
 	"\x04type\x18\x04 \x01(\tR\x04type\x12\x18\n" +
 	"\acontent\x18\x05 \x01(\tR\acontent\x12\x1c\n" +
-	"\ttimestamp\x18\x06 \x01(\tR\ttimestamp\"0\n" +
+	"\ttimestamp\x18\x06 \x01(\tR\ttimestamp\x12&\n" +
+	"\x0eidempotencyKey\x18\a \x01(\tR\x0eidempotencyKey\"0\n" +
 	"\x14NotificationResponse\x12\x18\n" +
 	"\amessage\x18\x01 \x01(\tR\amessage2n\n" +
 	"\x13NotificationService\x12W\n" +
@@ -0,0 +1,164 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultAPNsEndpoint = "https://api.push.apple.com"
+	// Apple rechaza tokens de proveedor con más de una hora; se renuevan antes
+	apnsTokenTTL = 50 * time.Minute
+)
+
+// APNsProvider envía a Apple Push Notification service con autenticación por token (.p8).
+// Endpoint se puede apuntar a un servidor HTTP local para pruebas.
+type APNsProvider struct {
+	KeyID    string
+	TeamID   string
+	Topic    string // bundle id de la app
+	Key      *ecdsa.PrivateKey
+	Endpoint string
+	Client   *http.Client
+
+	mu       sync.Mutex
+	jwtToken string
+	issuedAt time.Time
+}
+
+// LoadAPNsKey lee la clave .p8 (PKCS#8 EC) de APNs
+func LoadAPNsKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid APNs key PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("APNs key is not ECDSA")
+	}
+	return key, nil
+}
+
+// NewAPNsProvider crea el proveedor APNs
+func NewAPNsProvider(keyID, teamID, topic string, key *ecdsa.PrivateKey, endpoint string) *APNsProvider {
+	if endpoint == "" {
+		endpoint = defaultAPNsEndpoint
+	}
+	return &APNsProvider{
+		KeyID:    keyID,
+		TeamID:   teamID,
+		Topic:    topic,
+		Key:      key,
+		Endpoint: strings.TrimRight(endpoint, "/"),
+		Client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *APNsProvider) Send(ctx context.Context, token string, msg Message) error {
+	authToken, err := p.token()
+	if err != nil {
+		return fmt.Errorf("apns auth failed: %w", err)
+	}
+
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]string{
+				"title": msg.Title,
+				"body":  msg.Body,
+			},
+			"sound": "default",
+		},
+	}
+	for k, v := range msg.Data {
+		payload[k] = v
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint+"/3/device/"+token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("authorization", "bearer "+authToken)
+	req.Header.Set("apns-topic", p.Topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var apnsErr struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&apnsErr)
+
+	// 410 es siempre Unregistered. DeviceTokenNotForTopic suele ser un APNS_TOPIC mal configurado:
+	// borrar por eso eliminaría todos los tokens iOS, así que se trata como rechazo del mensaje.
+	switch {
+	case resp.StatusCode == http.StatusGone,
+		apnsErr.Reason == "BadDeviceToken",
+		apnsErr.Reason == "Unregistered":
+		return ErrInvalidToken
+	case resp.StatusCode == http.StatusBadRequest, resp.StatusCode == http.StatusRequestEntityTooLarge:
+		return fmt.Errorf("%w: apns %s %s", ErrRejected, resp.Status, apnsErr.Reason)
+	}
+	if err := transientError("apns", resp, apnsErr.Reason); err != nil {
+		return err
+	}
+	return fmt.Errorf("apns send failed: %s %s", resp.Status, apnsErr.Reason)
+}
+
+// token devuelve el JWT ES256 de proveedor, regenerándolo cada apnsTokenTTL
+func (p *APNsProvider) token() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.jwtToken != "" && time.Since(p.issuedAt) < apnsTokenTTL {
+		return p.jwtToken, nil
+	}
+
+	now := time.Now()
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": p.TeamID,
+		"iat": now.Unix(),
+	})
+	t.Header["kid"] = p.KeyID
+
+	signed, err := t.SignedString(p.Key)
+	if err != nil {
+		return "", err
+	}
+	p.jwtToken = signed
+	p.issuedAt = now
+	return signed, nil
+}
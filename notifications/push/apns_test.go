@@ -0,0 +1,83 @@
+package push
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newAPNsStandIn arranca un servidor que hace de APNs y contesta según el token del dispositivo
+func newAPNsStandIn(t *testing.T) *APNsProvider {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("authorization"), "bearer ") || r.Header.Get("apns-topic") != "com.example.app" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"reason": "InvalidProviderToken"})
+			return
+		}
+
+		reply := func(code int, reason string) {
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(map[string]string{"reason": reason})
+		}
+		switch strings.TrimPrefix(r.URL.Path, "/3/device/") {
+		case "ok":
+			w.WriteHeader(http.StatusOK)
+		case "unregistered":
+			reply(http.StatusGone, "Unregistered")
+		case "bad":
+			reply(http.StatusBadRequest, "BadDeviceToken")
+		case "wrong-topic":
+			reply(http.StatusBadRequest, "DeviceTokenNotForTopic")
+		case "too-large":
+			reply(http.StatusRequestEntityTooLarge, "PayloadTooLarge")
+		case "busy":
+			reply(http.StatusServiceUnavailable, "ServiceUnavailable")
+		case "throttled":
+			w.Header().Set("Retry-After", "2")
+			reply(http.StatusTooManyRequests, "TooManyRequests")
+		default:
+			reply(http.StatusInternalServerError, "InternalServerError")
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewAPNsProvider("KEY123", "TEAM123", "com.example.app", key, server.URL)
+}
+
+func TestAPNsProviderSend(t *testing.T) {
+	provider := newAPNsStandIn(t)
+	ctx := context.Background()
+	msg := Message{Title: "New like", Body: "hola"}
+
+	if err := provider.Send(ctx, "ok", msg); err != nil {
+		t.Fatalf("Send(ok) = %v", err)
+	}
+	for _, token := range []string{"unregistered", "bad"} {
+		if err := provider.Send(ctx, token, msg); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Send(%s) = %v, want ErrInvalidToken", token, err)
+		}
+	}
+	for _, token := range []string{"wrong-topic", "too-large"} {
+		if err := provider.Send(ctx, token, msg); !errors.Is(err, ErrRejected) || errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Send(%s) = %v, want ErrRejected without pruning", token, err)
+		}
+	}
+	for _, token := range []string{"busy", "throttled", "broken"} {
+		var transient *TransientError
+		if err := provider.Send(ctx, token, msg); !errors.As(err, &transient) {
+			t.Errorf("Send(%s) = %v, want TransientError", token, err)
+		}
+	}
+}
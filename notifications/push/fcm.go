@@ -0,0 +1,225 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultFCMEndpoint = "https://fcm.googleapis.com"
+	fcmScope           = "https://www.googleapis.com/auth/firebase.messaging"
+)
+
+// ServiceAccount son los campos usados del JSON de cuenta de servicio de Google
+type ServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// LoadServiceAccount lee el JSON de cuenta de servicio
+func LoadServiceAccount(path string) (ServiceAccount, error) {
+	var sa ServiceAccount
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return sa, err
+	}
+	if err := json.Unmarshal(data, &sa); err != nil {
+		return sa, err
+	}
+	if sa.ClientEmail == "" || sa.PrivateKey == "" || sa.TokenURI == "" {
+		return sa, errors.New("service account is missing client_email, private_key or token_uri")
+	}
+	return sa, nil
+}
+
+// FCMProvider envía con la API HTTP v1 de Firebase Cloud Messaging.
+// Endpoint y TokenURI se pueden apuntar a servidores HTTP locales para pruebas.
+type FCMProvider struct {
+	ProjectID string
+	Account   ServiceAccount
+	Endpoint  string
+	Client    *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewFCMProvider crea el proveedor FCM
+func NewFCMProvider(projectID string, account ServiceAccount, endpoint string) *FCMProvider {
+	if endpoint == "" {
+		endpoint = defaultFCMEndpoint
+	}
+	return &FCMProvider{
+		ProjectID: projectID,
+		Account:   account,
+		Endpoint:  strings.TrimRight(endpoint, "/"),
+		Client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type fcmError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func (p *FCMProvider) Send(ctx context.Context, token string, msg Message) error {
+	accessToken, err := p.token(ctx)
+	if err != nil {
+		return fmt.Errorf("fcm auth failed: %w", err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"message": map[string]interface{}{
+			"token": token,
+			"notification": map[string]string{
+				"title": msg.Title,
+				"body":  msg.Body,
+			},
+			"data": msg.Data,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/projects/%s/messages:send", p.Endpoint, p.ProjectID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var fe fcmError
+	data, _ := io.ReadAll(resp.Body)
+	_ = json.Unmarshal(data, &fe)
+
+	// Solo UNREGISTERED da el token de baja. INVALID_ARGUMENT también llega por un payload mal formado
+	// (o demasiado grande), y borrar por eso tokens válidos dejaría al usuario sin push.
+	for _, d := range fe.Error.Details {
+		switch d.ErrorCode {
+		case "UNREGISTERED":
+			return ErrInvalidToken
+		case "INVALID_ARGUMENT":
+			return fmt.Errorf("%w: fcm %s %s", ErrRejected, resp.Status, fe.Error.Message)
+		}
+	}
+	if err := transientError("fcm", resp, fe.Error.Status); err != nil {
+		return err
+	}
+	return fmt.Errorf("fcm send failed: %s %s", resp.Status, fe.Error.Message)
+}
+
+// token obtiene (y cachea) un access token OAuth2 firmando un JWT con la cuenta de servicio
+func (p *FCMProvider) token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.accessToken != "" && time.Now().Before(p.expiresAt) {
+		return p.accessToken, nil
+	}
+
+	key, err := parseRSAKey(p.Account.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   p.Account.ClientEmail,
+		"scope": fcmScope,
+		"aud":   p.Account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(key)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", err
+	}
+	if tokenResp.AccessToken == "" {
+		return "", errors.New("token endpoint returned no access_token")
+	}
+
+	p.accessToken = tokenResp.AccessToken
+	// Se renueva un minuto antes de que caduque
+	p.expiresAt = now.Add(time.Duration(tokenResp.ExpiresIn)*time.Second - time.Minute)
+	return p.accessToken, nil
+}
+
+func parseRSAKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("invalid PEM private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("service account key is not RSA")
+	}
+	return key, nil
+}
@@ -0,0 +1,157 @@
+package push
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fcmStandIn es un servidor que hace de endpoint OAuth2 y de API HTTP v1 de FCM.
+// respond contesta al envío según el token del dispositivo.
+type fcmStandIn struct {
+	server       *httptest.Server
+	tokenHits    atomic.Int32
+	mu           sync.Mutex
+	sendsByToken map[string]int
+}
+
+func newFCMStandIn(t *testing.T, respond func(w http.ResponseWriter, token string, attempt int)) (*fcmStandIn, *FCMProvider) {
+	t.Helper()
+	s := &fcmStandIn{sendsByToken: map[string]int{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		s.tokenHits.Add(1)
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || r.FormValue("assertion") == "" {
+			http.Error(w, "bad grant", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "test-access-token", "expires_in": 3600})
+	})
+	mux.HandleFunc("POST /v1/projects/test-project/messages:send", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-access-token" {
+			http.Error(w, "unauthenticated", http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Message struct {
+				Token string `json:"token"`
+			} `json:"message"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		s.mu.Lock()
+		s.sendsByToken[body.Message.Token]++
+		attempt := s.sendsByToken[body.Message.Token]
+		s.mu.Unlock()
+		respond(w, body.Message.Token, attempt)
+	})
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := ServiceAccount{
+		ClientEmail: "push@test-project.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		TokenURI:    s.server.URL + "/token",
+	}
+	return s, NewFCMProvider("test-project", account, s.server.URL)
+}
+
+func (s *fcmStandIn) sends(token string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sendsByToken[token]
+}
+
+// fcmErrorBody escribe un error de la API v1 con su errorCode de FCM
+func fcmErrorBody(w http.ResponseWriter, code int, status, errorCode string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	details := []map[string]string{}
+	if errorCode != "" {
+		details = append(details, map[string]string{
+			"@type":     "type.googleapis.com/google.firebase.fcm.v1.FcmError",
+			"errorCode": errorCode,
+		})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{"code": code, "status": status, "message": status, "details": details},
+	})
+}
+
+// fcmResponses contesta según el token: ok, unregistered, invalid, unavailable, quota o not-found
+func fcmResponses(w http.ResponseWriter, token string, attempt int) {
+	switch token {
+	case "ok":
+		w.Write([]byte(`{"name":"projects/test-project/messages/1"}`))
+	case "unregistered":
+		fcmErrorBody(w, http.StatusNotFound, "NOT_FOUND", "UNREGISTERED")
+	case "invalid":
+		fcmErrorBody(w, http.StatusBadRequest, "INVALID_ARGUMENT", "INVALID_ARGUMENT")
+	case "unavailable":
+		w.Header().Set("Retry-After", "7")
+		fcmErrorBody(w, http.StatusServiceUnavailable, "UNAVAILABLE", "UNAVAILABLE")
+	case "quota":
+		fcmErrorBody(w, http.StatusTooManyRequests, "RESOURCE_EXHAUSTED", "QUOTA_EXCEEDED")
+	case "flaky":
+		// Dos 503 y luego éxito
+		if attempt <= 2 {
+			fcmErrorBody(w, http.StatusServiceUnavailable, "UNAVAILABLE", "UNAVAILABLE")
+			return
+		}
+		w.Write([]byte(`{"name":"projects/test-project/messages/2"}`))
+	default:
+		// Un 404 sin errorCode (proyecto o endpoint mal configurado) no da de baja el token
+		fcmErrorBody(w, http.StatusNotFound, "NOT_FOUND", "")
+	}
+}
+
+func TestFCMProviderSend(t *testing.T) {
+	standIn, provider := newFCMStandIn(t, fcmResponses)
+	ctx := context.Background()
+	msg := Message{Title: "New like", Body: "hola", Data: map[string]string{"type": "like"}}
+
+	if err := provider.Send(ctx, "ok", msg); err != nil {
+		t.Fatalf("Send(ok) = %v", err)
+	}
+
+	if err := provider.Send(ctx, "unregistered", msg); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Send(unregistered) = %v, want ErrInvalidToken", err)
+	}
+
+	err := provider.Send(ctx, "invalid", msg)
+	if !errors.Is(err, ErrRejected) || errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Send(invalid) = %v, want ErrRejected without pruning", err)
+	}
+
+	var transient *TransientError
+	if err := provider.Send(ctx, "unavailable", msg); !errors.As(err, &transient) ||
+		transient.StatusCode != http.StatusServiceUnavailable || transient.RetryAfter != 7*time.Second {
+		t.Errorf("Send(unavailable) = %#v, want TransientError 503 with Retry-After 7s", err)
+	}
+	if err := provider.Send(ctx, "quota", msg); !errors.As(err, &transient) || transient.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Send(quota) = %v, want TransientError 429", err)
+	}
+
+	err = provider.Send(ctx, "misconfigured", msg)
+	if err == nil || errors.Is(err, ErrInvalidToken) || errors.As(err, &transient) {
+		t.Errorf("Send(misconfigured) = %v, want a plain error", err)
+	}
+
+	// El access token se pide una vez y se reutiliza
+	if hits := standIn.tokenHits.Load(); hits != 1 {
+		t.Errorf("token endpoint called %d times, want 1", hits)
+	}
+}
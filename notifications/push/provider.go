@@ -0,0 +1,70 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidToken indica que el proveedor dio el token de baja (FCM UNREGISTERED, APNs BadDeviceToken o
+// Unregistered); el token se elimina del registro
+var ErrInvalidToken = errors.New("invalid device token")
+
+// ErrRejected indica que el proveedor rechazó este mensaje de forma permanente (por ejemplo FCM
+// INVALID_ARGUMENT por un payload inválido). Reintentar no sirve, pero el token sigue siendo válido.
+var ErrRejected = errors.New("push message rejected")
+
+// TransientError es un fallo temporal del proveedor (429 o 5xx) que merece reintentarse.
+// RetryAfter es la espera que pidió el proveedor con Retry-After, si la indicó.
+type TransientError struct {
+	Provider   string
+	StatusCode int
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *TransientError) Error() string {
+	return fmt.Sprintf("%s send failed: %d %s", e.Provider, e.StatusCode, e.Reason)
+}
+
+// transientError devuelve un *TransientError si la respuesta es 429 o 5xx, o nil en otro caso
+func transientError(provider string, resp *http.Response, reason string) error {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return nil
+	}
+	return &TransientError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Reason:     reason,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter acepta segundos o una fecha HTTP; 0 si no hay cabecera o no es válida
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// Message es el contenido de una notificación push
+type Message struct {
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// PushProvider envía un mensaje a un token de dispositivo (FCM, APNs, ...)
+type PushProvider interface {
+	Send(ctx context.Context, token string, msg Message) error
+}
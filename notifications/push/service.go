@@ -0,0 +1,176 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"notifications/lifecycle"
+	"notifications/models"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Service envía una notificación a todos los dispositivos registrados del usuario,
+// eligiendo el proveedor según la plataforma, y elimina los tokens rechazados
+type Service struct {
+	DB        *gorm.DB
+	Providers map[string]PushProvider // por plataforma (android, ios, web)
+	// Retry reintenta por dispositivo los fallos temporales (429 y 5xx). Se hace aquí y no en el outbox
+	// porque el outbox reenviaría también a los dispositivos que ya la recibieron.
+	Retry Retry
+	// Timeout acota cada Notify con todos sus reintentos; por defecto defaultTimeout. Tiene que quedar
+	// por debajo del lease del outbox: si no, otra réplica reclamaría el evento y lo enviaría de nuevo.
+	Timeout time.Duration
+}
+
+// defaultTimeout deja margen dentro del lease por defecto del outbox (2 min)
+const defaultTimeout = time.Minute
+
+func (s *Service) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return defaultTimeout
+}
+
+// Retry define los reintentos con backoff exponencial
+type Retry struct {
+	Attempts int
+	Backoff  time.Duration // espera antes del segundo intento; se duplica en cada intento
+}
+
+// maxRetryAfter es la mayor espera que se hace dentro de un envío; si el proveedor pide más, el fallo
+// se devuelve y el outbox lo reintenta en su siguiente ciclo
+var maxRetryAfter = 30 * time.Second
+
+// Notify envía la notificación a los dispositivos del destinatario en paralelo, de modo que los
+// reintentos de uno no retrasan a los demás, y nunca tarda más que Timeout.
+// Devuelve error solo si ningún dispositivo la recibió y alguno falló.
+func (s *Service) Notify(ctx context.Context, noti models.Notification, group *models.NotificationGroup) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+
+	var devices []models.DeviceToken
+	if err := s.DB.WithContext(ctx).Where(`"userId" = ?`, noti.ResponsibleID).Find(&devices).Error; err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		lifecycle.Delivery(s.DB, noti.ID, noti.ResponsibleID, models.ChannelPush, models.DeliveryResultSkipped, "no registered devices")
+		return nil
+	}
+
+	msg := buildMessage(noti, group)
+	errs := make([]error, len(devices))
+	sent := make([]bool, len(devices))
+	var wg sync.WaitGroup
+	for i, device := range devices {
+		provider, ok := s.Providers[device.Platform]
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.send(ctx, provider, device.Token, msg)
+			sent[i] = true
+		}()
+	}
+	wg.Wait()
+
+	delivered := 0
+	var lastErr, rejectedErr error
+	for i, device := range devices {
+		if !sent[i] {
+			continue
+		}
+		switch err := errs[i]; {
+		case err == nil:
+			delivered++
+			now := time.Now()
+			s.DB.Model(&device).Update("lastUsedAt", now)
+		case errors.Is(err, ErrInvalidToken):
+			slog.Info("Pruning invalid device token", "platform", device.Platform, "device_id", device.ID, "user_id", device.UserID)
+			if err := s.DB.Delete(&device).Error; err != nil {
+				slog.Error("Could not prune device token", "device_id", device.ID, "error", err)
+			}
+		case errors.Is(err, ErrRejected):
+			slog.Warn("Push message rejected", "device_id", device.ID, "user_id", device.UserID, "error", err)
+			rejectedErr = err
+		default:
+			slog.Warn("Push delivery failed", "device_id", device.ID, "user_id", device.UserID, "error", err)
+			lastErr = err
+		}
+	}
+
+	if delivered == 0 && lastErr != nil {
+		lifecycle.Delivery(s.DB, noti.ID, noti.ResponsibleID, models.ChannelPush, models.DeliveryResultFailed, lastErr.Error())
+		return fmt.Errorf("push not delivered: %w", lastErr)
+	}
+	// Un rechazo permanente se registra como fallo pero no devuelve error: el outbox lo reintentaría en vano
+	if delivered == 0 && rejectedErr != nil {
+		lifecycle.Delivery(s.DB, noti.ID, noti.ResponsibleID, models.ChannelPush, models.DeliveryResultFailed, rejectedErr.Error())
+		return nil
+	}
+
+	result := models.DeliveryResultOK
+	if delivered == 0 {
+		result = models.DeliveryResultSkipped
+	}
+	lifecycle.Delivery(s.DB, noti.ID, noti.ResponsibleID, models.ChannelPush, result,
+		fmt.Sprintf("%d of %d devices", delivered, len(devices)))
+	return nil
+}
+
+// send envía a un dispositivo reintentando los *TransientError con backoff exponencial, o con la espera
+// de Retry-After si es mayor
+func (s *Service) send(ctx context.Context, provider PushProvider, token string, msg Message) error {
+	attempts := max(s.Retry.Attempts, 1)
+	wait := s.Retry.Backoff
+	for attempt := 1; ; attempt++ {
+		err := provider.Send(ctx, token, msg)
+		var transient *TransientError
+		if err == nil || !errors.As(err, &transient) || attempt >= attempts {
+			return err
+		}
+
+		delay := max(wait, transient.RetryAfter)
+		if delay > maxRetryAfter {
+			return err
+		}
+		// Si la espera no cabe en el plazo de Notify no se espera: el outbox reintenta más tarde
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		wait *= 2
+	}
+}
+
+func buildMessage(noti models.Notification, group *models.NotificationGroup) Message {
+	title := "New notification"
+	switch noti.Type {
+	case "like":
+		title = "New like"
+	case "follow":
+		title = "New follower"
+	}
+
+	data := map[string]string{
+		"notificationId": noti.ID.String(),
+		"type":           noti.Type,
+		"target":         noti.Target,
+	}
+	if group != nil && group.Count > 1 {
+		title = fmt.Sprintf("%s (+%d)", title, group.Count-1)
+		data["groupId"] = group.ID.String()
+	}
+
+	return Message{Title: title, Body: noti.Content, Data: data}
+}
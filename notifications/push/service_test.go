@@ -0,0 +1,112 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"notifications/internal/testdb"
+	"notifications/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestServiceRetriesTransientFailures(t *testing.T) {
+	standIn, provider := newFCMStandIn(t, fcmResponses)
+	s := &Service{Retry: Retry{Attempts: 3, Backoff: time.Millisecond}}
+	ctx := context.Background()
+
+	// 503, 503 y 200: se entrega al tercer intento
+	if err := s.send(ctx, provider, "flaky", Message{}); err != nil {
+		t.Fatalf("send(flaky) = %v", err)
+	}
+	if n := standIn.sends("flaky"); n != 3 {
+		t.Errorf("flaky token sent %d times, want 3", n)
+	}
+
+	// 429 sin Retry-After: se agotan los intentos
+	var transient *TransientError
+	if err := s.send(ctx, provider, "quota", Message{}); !errors.As(err, &transient) || transient.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("send(quota) = %v, want TransientError 429", err)
+	}
+	if n := standIn.sends("quota"); n != 3 {
+		t.Errorf("quota token sent %d times, want 3", n)
+	}
+
+	// Los fallos permanentes no se reintentan
+	for _, token := range []string{"unregistered", "invalid"} {
+		s.send(ctx, provider, token, Message{})
+		if n := standIn.sends(token); n != 1 {
+			t.Errorf("%s token sent %d times, want 1", token, n)
+		}
+	}
+}
+
+func TestServiceDoesNotWaitForLongRetryAfter(t *testing.T) {
+	_, provider := newFCMStandIn(t, fcmResponses)
+	s := &Service{Retry: Retry{Attempts: 3, Backoff: time.Millisecond}}
+
+	// Retry-After de 7s con un máximo menor: se devuelve el fallo y el outbox reintenta más tarde
+	prevMax := maxRetryAfter
+	defer func() { maxRetryAfter = prevMax }()
+	maxRetryAfter = time.Second
+
+	start := time.Now()
+	var transient *TransientError
+	if err := s.send(context.Background(), provider, "unavailable", Message{}); !errors.As(err, &transient) {
+		t.Fatalf("send(unavailable) = %v, want TransientError", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("send waited %s for a Retry-After above the maximum", elapsed)
+	}
+}
+
+func TestServiceDoesNotWaitPastTheNotifyDeadline(t *testing.T) {
+	standIn, provider := newFCMStandIn(t, fcmResponses)
+	s := &Service{Retry: Retry{Attempts: 3, Backoff: time.Second}}
+
+	// La espera de 1s no cabe en el plazo: se devuelve el fallo sin esperar y sin más intentos
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	var transient *TransientError
+	if err := s.send(ctx, provider, "quota", Message{}); !errors.As(err, &transient) {
+		t.Fatalf("send(quota) = %v, want TransientError", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("send waited %s past the deadline", elapsed)
+	}
+	if n := standIn.sends("quota"); n != 1 {
+		t.Errorf("quota token sent %d times, want 1", n)
+	}
+}
+
+func TestNotifyPrunesOnlyUnregisteredTokens(t *testing.T) {
+	db := testdb.Open(t)
+	_, provider := newFCMStandIn(t, fcmResponses)
+	s := &Service{DB: db, Providers: map[string]PushProvider{models.PlatformAndroid: provider}, Retry: Retry{Attempts: 1}}
+
+	userID := uuid.New()
+	for _, token := range []string{"ok", "unregistered", "invalid"} {
+		if err := db.Create(&models.DeviceToken{UserID: userID, Token: token, Platform: models.PlatformAndroid, CreatedAt: time.Now()}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	noti := models.Notification{ID: uuid.New(), ResponsibleID: userID, Type: "like", Content: "hola"}
+
+	if err := s.Notify(context.Background(), noti, nil); err != nil {
+		t.Fatalf("Notify = %v", err)
+	}
+
+	var remaining []models.DeviceToken
+	if err := db.Where(`"userId" = ?`, userID).Order("token").Find(&remaining).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 2 || remaining[0].Token != "invalid" || remaining[1].Token != "ok" {
+		t.Fatalf("remaining tokens = %+v, want invalid and ok", remaining)
+	}
+	if remaining[1].LastUsedAt == nil {
+		t.Error("lastUsedAt not updated for the delivered token")
+	}
+}
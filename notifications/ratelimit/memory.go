@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"notifications/clock"
+	"sync"
+	"time"
+)
+
+// sweepInterval es cada cuánto se eliminan los buckets que ya están llenos
+const sweepInterval = time.Minute
+
+// MemoryStore guarda los buckets en memoria. Cada réplica aplica su propio límite.
+type MemoryStore struct {
+	Clock clock.Clock
+
+	mu        sync.Mutex
+	buckets   map[string]*Bucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore crea un almacén en memoria con reloj real
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{Clock: clock.Real{}}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	now := s.Clock.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.buckets == nil {
+		s.buckets = make(map[string]*Bucket)
+	}
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = NewBucket(limit, now)
+		s.buckets[key] = b
+	}
+	b.limit = limit
+	return b.Take(now), nil
+}
+
+// sweep elimina los buckets llenos: volver a crearlos da el mismo resultado y así la memoria
+// no crece con cada IP o destinatario visto
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if b.full(now) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// El bucket se rellena y se consume en una sola sentencia atómica. Si no hay token el
+// ON CONFLICT no actualiza la fila y no se devuelve nada. Se usa la hora de Postgres para
+// que las réplicas no dependan de tener los relojes sincronizados.
+const takeSQL = `
+INSERT INTO "RateLimitBuckets" AS b (key, tokens, "updatedAt")
+VALUES (@key, CAST(@burst AS DOUBLE PRECISION) - 1, NOW())
+ON CONFLICT (key) DO UPDATE
+SET tokens = LEAST(CAST(@burst AS DOUBLE PRECISION),
+        b.tokens + GREATEST(0, CAST(EXTRACT(EPOCH FROM NOW() - b."updatedAt") AS DOUBLE PRECISION)) * CAST(@rate AS DOUBLE PRECISION)) - 1,
+    "updatedAt" = NOW()
+WHERE LEAST(CAST(@burst AS DOUBLE PRECISION),
+        b.tokens + GREATEST(0, CAST(EXTRACT(EPOCH FROM NOW() - b."updatedAt") AS DOUBLE PRECISION)) * CAST(@rate AS DOUBLE PRECISION)) >= 1
+RETURNING tokens`
+
+// availableSQL calcula los tokens disponibles sin consumir, para el Retry-After de un rechazo
+const availableSQL = `
+SELECT LEAST(CAST(@burst AS DOUBLE PRECISION),
+        tokens + GREATEST(0, CAST(EXTRACT(EPOCH FROM NOW() - "updatedAt") AS DOUBLE PRECISION)) * CAST(@rate AS DOUBLE PRECISION))
+FROM "RateLimitBuckets" WHERE key = @key`
+
+// PostgresStore guarda los buckets en "RateLimitBuckets" para que el límite se aplique entre
+// todas las réplicas
+type PostgresStore struct {
+	DB *gorm.DB
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	return s.TakeTx(s.DB.WithContext(ctx), key, limit)
+}
+
+// TakeTx consume el token con tx: si la transacción se revierte el token vuelve al bucket. La fila
+// del bucket queda bloqueada hasta el commit, así que las transacciones del mismo key se serializan.
+func (s *PostgresStore) TakeTx(tx *gorm.DB, key string, limit Limit) (Decision, error) {
+	args := map[string]interface{}{"key": key, "burst": float64(limit.Burst), "rate": limit.Rate}
+
+	var remaining []float64
+	if err := tx.Raw(takeSQL, args).Scan(&remaining).Error; err != nil {
+		return Decision{}, err
+	}
+	if len(remaining) > 0 {
+		return Decision{Allowed: true, Remaining: int(remaining[0])}, nil
+	}
+
+	var available []float64
+	if err := tx.Raw(availableSQL, args).Scan(&available).Error; err != nil {
+		return Decision{}, err
+	}
+	if len(available) == 0 || available[0] >= 1 {
+		// Otra petición rellenó o borró el bucket entre las dos consultas: se rechaza igualmente
+		return Decision{Allowed: false}, nil
+	}
+	return limit.decide(available[0]), nil
+}
+
+// PurgeIdle borra periódicamente los buckets sin uso desde hace más de idle; un bucket
+// inexistente equivale a uno lleno
+func (s *PostgresStore) PurgeIdle(interval, idle time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.DB.Exec(`DELETE FROM "RateLimitBuckets" WHERE "updatedAt" < NOW() - make_interval(secs => ?)`, idle.Seconds()).Error; err != nil {
+			slog.Error("Error purging idle rate limit buckets", "error", err)
+		}
+	}
+}
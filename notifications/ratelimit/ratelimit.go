@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Limit describe un token bucket: se rellena a Rate tokens por segundo hasta Burst
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute crea un límite de n peticiones por minuto con la ráfaga indicada.
+// Si burst no es positivo se usa n.
+func PerMinute(n, burst int) Limit {
+	if burst <= 0 {
+		burst = n
+	}
+	return Limit{Rate: float64(n) / 60, Burst: burst}
+}
+
+// Enabled indica si el límite está activo; un Rate o Burst no positivo lo desactiva
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// Decision es el resultado de consumir un token
+type Decision struct {
+	Allowed bool
+	// Remaining son los tokens que quedan tras la petición
+	Remaining int
+	// RetryAfter es cuánto falta para que haya un token disponible; 0 si se permitió
+	RetryAfter time.Duration
+}
+
+// Store guarda los buckets. MemoryStore sirve para una réplica; PostgresStore comparte los
+// límites entre réplicas.
+type Store interface {
+	// Take consume un token del bucket key con el límite indicado
+	Take(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// TxStore es un Store que también consume dentro de una transacción de la base de datos, de modo que
+// el token solo se gasta si la transacción se confirma
+type TxStore interface {
+	Store
+	// TakeTx consume un token del bucket key usando tx, con su contexto
+	TakeTx(tx *gorm.DB, key string, limit Limit) (Decision, error)
+}
+
+// Bucket es un token bucket individual, sin sincronización: sirve para estado que solo toca una
+// goroutine, como el bucket de cada conexión WebSocket
+type Bucket struct {
+	limit   Limit
+	tokens  float64
+	updated time.Time
+}
+
+// NewBucket crea un bucket lleno
+func NewBucket(limit Limit, now time.Time) *Bucket {
+	return &Bucket{limit: limit, tokens: float64(limit.Burst), updated: now}
+}
+
+// Take rellena el bucket hasta now y consume un token si hay
+func (b *Bucket) Take(now time.Time) Decision {
+	b.tokens = b.limit.refill(b.tokens, now.Sub(b.updated))
+	b.updated = now
+
+	decision := b.limit.decide(b.tokens)
+	if decision.Allowed {
+		b.tokens--
+	}
+	return decision
+}
+
+// full indica si en now el bucket estaría lleno
+func (b *Bucket) full(now time.Time) bool {
+	return b.limit.refill(b.tokens, now.Sub(b.updated)) >= float64(b.limit.Burst)
+}
+
+// refill devuelve los tokens disponibles tras elapsed desde la última actualización
+func (l Limit) refill(tokens float64, elapsed time.Duration) float64 {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(float64(l.Burst), tokens+elapsed.Seconds()*l.Rate)
+}
+
+// decide calcula la decisión para los tokens disponibles antes de consumir
+func (l Limit) decide(available float64) Decision {
+	if available >= 1 {
+		return Decision{Allowed: true, Remaining: int(available - 1)}
+	}
+	wait := time.Duration((1 - available) / l.Rate * float64(time.Second))
+	return Decision{Allowed: false, RetryAfter: wait}
+}
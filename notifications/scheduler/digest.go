@@ -0,0 +1,212 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"notifications/clock"
+	"notifications/health"
+	"notifications/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Digest es el resumen de notificaciones retenidas de un usuario
+type Digest struct {
+	UserID        uuid.UUID
+	WindowEnd     time.Time
+	Notifications []models.Notification
+}
+
+// DigestSink entrega un resumen al usuario. Se llama fuera de cualquier transacción y puede repetirse
+// si el proceso muere antes de marcar el resumen como entregado.
+type DigestSink interface {
+	DeliverDigest(ctx context.Context, digest Digest) error
+}
+
+// SinkFunc adapta una función a DigestSink
+type SinkFunc func(ctx context.Context, digest Digest) error
+
+func (f SinkFunc) DeliverDigest(ctx context.Context, digest Digest) error { return f(ctx, digest) }
+
+// Hold retiene la notificación hasta deliverAt. Se llama dentro de la transacción de creación
+// para que la retención sobreviva a reinicios igual que la notificación.
+func Hold(tx *gorm.DB, noti models.Notification, deliverAt time.Time) error {
+	return tx.Create(&models.DigestItem{
+		ID:             uuid.New(),
+		UserID:         noti.ResponsibleID,
+		NotificationID: noti.ID,
+		DeliverAt:      deliverAt,
+		CreatedAt:      time.Now(),
+	}).Error
+}
+
+// HeldScope excluye las notificaciones retenidas pendientes de resumen
+func HeldScope(db *gorm.DB) *gorm.DB {
+	return db.Where(`id NOT IN (SELECT "notificationId" FROM "DigestItems" WHERE "deliveredAt" IS NULL)`)
+}
+
+// Scheduler entrega periódicamente los resúmenes cuya ventana terminó
+type Scheduler struct {
+	DB       *gorm.DB
+	Clock    clock.Clock
+	Sink     DigestSink
+	Interval time.Duration
+	// BatchSize limita cuántos elementos se reclaman por ciclo
+	BatchSize int
+	// Lease es cuánto se aparta un lote reclamado antes de que otra réplica pueda tomarlo;
+	// por defecto defaultLease
+	Lease time.Duration
+	// Heartbeat late en cada vuelta para /readyz; opcional
+	Heartbeat *health.Heartbeat
+}
+
+// New crea un scheduler con reloj real
+func New(db *gorm.DB, sink DigestSink, interval time.Duration) *Scheduler {
+	return &Scheduler{DB: db, Clock: clock.Real{}, Sink: sink, Interval: interval, BatchSize: 500}
+}
+
+// Run procesa resúmenes vencidos en cada tick hasta que ctx se cancele.
+// Al arrancar procesa de inmediato lo que venció mientras el servicio estaba caído.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := s.Clock.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := s.RunOnce(ctx)
+		if err != nil {
+			slog.Error("Digest scheduler error", "error", err)
+		}
+		s.Heartbeat.Beat(err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// defaultLease cubre la entrega de un lote de resúmenes
+const defaultLease = 2 * time.Minute
+
+func (s *Scheduler) lease() time.Duration {
+	if s.Lease > 0 {
+		return s.Lease
+	}
+	return defaultLease
+}
+
+// RunOnce entrega los resúmenes vencidos y devuelve cuántos usuarios recibieron uno.
+// Los elementos se reclaman en una transacción corta que los aparta durante Lease y el resumen se
+// entrega fuera de ella; después se marcan como entregados o, si la entrega falló, se liberan para
+// el siguiente ciclo. La entrega es al menos una vez: si el proceso muere entre entregar y marcar,
+// el resumen se repite al vencer el lease.
+func (s *Scheduler) RunOnce(ctx context.Context) (int, error) {
+	items, leaseUntil, err := s.claim(ctx)
+	if err != nil || len(items) == 0 {
+		return 0, err
+	}
+
+	byUser := map[uuid.UUID][]models.DigestItem{}
+	var users []uuid.UUID
+	for _, item := range items {
+		if _, ok := byUser[item.UserID]; !ok {
+			users = append(users, item.UserID)
+		}
+		byUser[item.UserID] = append(byUser[item.UserID], item)
+	}
+
+	delivered := 0
+	for _, userID := range users {
+		userItems := byUser[userID]
+		digest, err := s.buildDigest(s.DB.WithContext(ctx), userID, userItems)
+		if err != nil {
+			s.finish(ctx, userItems, leaseUntil, err)
+			return delivered, err
+		}
+
+		// Si todas se leyeron o eliminaron durante la noche no hay nada que enviar
+		if len(digest.Notifications) > 0 {
+			err = s.Sink.DeliverDigest(ctx, digest)
+			if err != nil {
+				slog.Warn("Could not deliver digest", "user_id", userID, "error", err)
+			} else {
+				delivered++
+			}
+		}
+		s.finish(ctx, userItems, leaseUntil, err)
+	}
+	return delivered, nil
+}
+
+// claim reclama un lote con FOR UPDATE SKIP LOCKED y lo aparta hasta el fin del lease, que hace de
+// token al marcarlo; los elementos con un lease vencido vuelven a estar disponibles
+func (s *Scheduler) claim(ctx context.Context) ([]models.DigestItem, time.Time, error) {
+	now := s.Clock.Now()
+	// Truncado a microsegundos, la precisión de Postgres, para poder compararlo después
+	leaseUntil := now.Add(s.lease()).Truncate(time.Microsecond)
+
+	var items []models.DigestItem
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where(`"deliveredAt" IS NULL AND "deliverAt" <= ?`, now).
+			Where(`("claimedUntil" IS NULL OR "claimedUntil" <= ?)`, now).
+			Order(`"userId", "deliverAt"`).
+			Limit(s.BatchSize).
+			Find(&items).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return tx.Model(&models.DigestItem{}).Where("id IN ?", digestItemIDs(items)).
+			Update("claimedUntil", leaseUntil).Error
+	})
+	return items, leaseUntil, err
+}
+
+// finish marca los elementos como entregados o, si deliverErr no es nil, los libera para el
+// siguiente ciclo. Solo actualiza los que siguen bajo este lease.
+func (s *Scheduler) finish(ctx context.Context, items []models.DigestItem, leaseUntil time.Time, deliverErr error) {
+	updates := map[string]interface{}{"deliveredAt": s.Clock.Now()}
+	if deliverErr != nil {
+		updates = map[string]interface{}{"claimedUntil": nil}
+	}
+	result := s.DB.WithContext(ctx).Model(&models.DigestItem{}).
+		Where(`id IN ? AND "claimedUntil" = ?`, digestItemIDs(items), leaseUntil).
+		Updates(updates)
+	if result.Error != nil {
+		slog.Error("Could not update digest items", "user_id", items[0].UserID, "error", result.Error)
+	} else if result.RowsAffected < int64(len(items)) {
+		slog.Warn("Digest lease expired before recording the result", "user_id", items[0].UserID)
+	}
+}
+
+func digestItemIDs(items []models.DigestItem) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
+func (s *Scheduler) buildDigest(tx *gorm.DB, userID uuid.UUID, items []models.DigestItem) (Digest, error) {
+	digest := Digest{UserID: userID}
+	ids := make([]uuid.UUID, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.NotificationID)
+		if item.DeliverAt.After(digest.WindowEnd) {
+			digest.WindowEnd = item.DeliverAt
+		}
+	}
+
+	if err := tx.Where("id IN ? AND read = ?", ids, false).
+		Order("timestamp DESC").
+		Find(&digest.Notifications).Error; err != nil {
+		return digest, err
+	}
+	return digest, nil
+}
@@ -0,0 +1,199 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"notifications/clock"
+	"notifications/internal/testdb"
+	"notifications/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// holdNotification guarda una notificación para userID y la retiene hasta deliverAt
+func holdNotification(t *testing.T, db *gorm.DB, userID uuid.UUID, at, deliverAt time.Time) models.Notification {
+	t.Helper()
+	noti := models.Notification{
+		ActorID:       uuid.New(),
+		RecipientID:   uuid.New(),
+		ResponsibleID: userID,
+		Type:          "like",
+		Content:       "held",
+		Target:        "post",
+		Timestamp:     at,
+	}
+	if err := db.Create(&noti).Error; err != nil {
+		t.Fatalf("create notification: %v", err)
+	}
+	if err := Hold(db, noti, deliverAt); err != nil {
+		t.Fatalf("hold notification: %v", err)
+	}
+	return noti
+}
+
+func visible(t *testing.T, db *gorm.DB, userID uuid.UUID) int64 {
+	t.Helper()
+	var count int64
+	if err := db.Model(&models.Notification{}).Where(`"responsibleId" = ?`, userID).
+		Scopes(HeldScope).Count(&count).Error; err != nil {
+		t.Fatalf("count visible notifications: %v", err)
+	}
+	return count
+}
+
+func TestSchedulerReleasesDigestsAtWindowEnd(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+
+	night := time.Date(2024, 1, 15, 23, 0, 0, 0, time.UTC)
+	windowEnd := time.Date(2024, 1, 16, 7, 0, 0, 0, time.UTC)
+	laterWindowEnd := windowEnd.Add(2 * time.Hour)
+	fake := clock.NewFake(night)
+
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	first := holdNotification(t, db, alice, night, windowEnd)
+	read := holdNotification(t, db, alice, night.Add(time.Hour), windowEnd)
+	last := holdNotification(t, db, alice, night.Add(2*time.Hour), windowEnd)
+	holdNotification(t, db, bob, night.Add(time.Hour), windowEnd)
+	holdNotification(t, db, carol, night, laterWindowEnd)
+
+	// Leída durante la noche: se marca entregada pero no va en el resumen
+	if err := db.Model(&read).Update("read", true).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var digests []Digest
+	s := &Scheduler{
+		DB:        db,
+		Clock:     fake,
+		Interval:  time.Minute,
+		BatchSize: 500,
+		Sink: SinkFunc(func(_ context.Context, digest Digest) error {
+			digests = append(digests, digest)
+			return nil
+		}),
+	}
+
+	run := func(want int) {
+		t.Helper()
+		delivered, err := s.RunOnce(ctx)
+		if err != nil {
+			t.Fatalf("RunOnce: %v", err)
+		}
+		if delivered != want {
+			t.Fatalf("RunOnce at %s delivered %d digests, want %d", fake.Now().Format(time.RFC3339), delivered, want)
+		}
+	}
+
+	// Retenidas: no se entregan ni aparecen como pendientes hasta el fin de la ventana
+	run(0)
+	fake.Set(windowEnd.Add(-time.Second))
+	run(0)
+	if n := visible(t, db, alice); n != 0 {
+		t.Fatalf("%d held notifications visible before the window ends", n)
+	}
+
+	// Al terminar la ventana llega un único resumen por usuario con todas sus retenidas
+	fake.Set(windowEnd)
+	run(2)
+	byUser := map[uuid.UUID]Digest{}
+	for _, digest := range digests {
+		byUser[digest.UserID] = digest
+	}
+	aliceDigest, ok := byUser[alice]
+	if !ok {
+		t.Fatal("no digest for alice")
+	}
+	if !aliceDigest.WindowEnd.Equal(windowEnd) {
+		t.Errorf("WindowEnd = %s, want %s", aliceDigest.WindowEnd, windowEnd)
+	}
+	if len(aliceDigest.Notifications) != 2 ||
+		aliceDigest.Notifications[0].ID != last.ID || aliceDigest.Notifications[1].ID != first.ID {
+		t.Errorf("alice digest = %+v, want [last, first] without the read one", aliceDigest.Notifications)
+	}
+	if len(byUser[bob].Notifications) != 1 {
+		t.Errorf("bob digest has %d notifications, want 1", len(byUser[bob].Notifications))
+	}
+	if _, ok := byUser[carol]; ok {
+		t.Error("carol's window has not ended yet")
+	}
+	if n := visible(t, db, alice); n != 3 {
+		t.Errorf("%d notifications visible after the digest, want 3", n)
+	}
+
+	// Cada elemento se entrega una sola vez
+	run(0)
+	fake.Set(laterWindowEnd)
+	run(1)
+	if digests[len(digests)-1].UserID != carol {
+		t.Error("expected carol's digest at her window end")
+	}
+}
+
+func TestSchedulerReleasesItemsWhenDeliveryFails(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	windowEnd := time.Date(2024, 1, 16, 7, 0, 0, 0, time.UTC)
+	user := uuid.New()
+	holdNotification(t, db, user, windowEnd.Add(-time.Hour), windowEnd)
+
+	fake := clock.NewFake(windowEnd)
+	failing := true
+	var attempts int
+	s := &Scheduler{
+		DB:        db,
+		Clock:     fake,
+		Interval:  time.Minute,
+		BatchSize: 500,
+		Lease:     time.Minute,
+		Sink: SinkFunc(func(_ context.Context, digest Digest) error {
+			attempts++
+			if failing {
+				return errors.New("sink unavailable")
+			}
+			return nil
+		}),
+	}
+
+	// Un fallo libera el lote de inmediato: el siguiente ciclo lo reintenta sin esperar al lease
+	if delivered, err := s.RunOnce(ctx); err != nil || delivered != 0 {
+		t.Fatalf("RunOnce = %d, %v; want 0 delivered", delivered, err)
+	}
+	failing = false
+	if delivered, err := s.RunOnce(ctx); err != nil || delivered != 1 {
+		t.Fatalf("RunOnce after failure = %d, %v; want 1 delivered", delivered, err)
+	}
+	if delivered, _ := s.RunOnce(ctx); delivered != 0 || attempts != 2 {
+		t.Errorf("digest delivered again (attempts = %d)", attempts)
+	}
+}
+
+func TestSchedulerLeaseKeepsOtherReplicasOut(t *testing.T) {
+	db := testdb.Open(t)
+	ctx := context.Background()
+	windowEnd := time.Date(2024, 1, 16, 7, 0, 0, 0, time.UTC)
+	holdNotification(t, db, uuid.New(), windowEnd.Add(-time.Hour), windowEnd)
+
+	fake := clock.NewFake(windowEnd)
+	replica := &Scheduler{DB: db, Clock: fake, Interval: time.Minute, BatchSize: 500, Lease: time.Minute}
+
+	// Una réplica que reclamó el lote y murió antes de entregarlo
+	items, _, err := replica.claim(ctx)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("claim = %d items, %v", len(items), err)
+	}
+
+	var delivered int
+	other := &Scheduler{DB: db, Clock: fake, Interval: time.Minute, BatchSize: 500, Lease: time.Minute,
+		Sink: SinkFunc(func(context.Context, Digest) error { delivered++; return nil })}
+	if _, err := other.RunOnce(ctx); err != nil || delivered != 0 {
+		t.Fatalf("other replica delivered %d digests under a live lease (err %v)", delivered, err)
+	}
+	fake.Advance(time.Minute)
+	if _, err := other.RunOnce(ctx); err != nil || delivered != 1 {
+		t.Fatalf("other replica delivered %d digests after the lease expired (err %v), want 1", delivered, err)
+	}
+}
@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName identifica al servicio en las trazas y es el nombre de su tracer
+const ServiceName = "notifications"
+
+// Setup configura el TracerProvider global y la propagación W3C (traceparent y baggage) a partir de:
+//
+//	OTEL_TRACES_EXPORTER=none|stdout|otlp   (none)
+//	OTEL_TRACES_SAMPLER_RATIO=0..1          (1) fracción de trazas nuevas que se muestrean
+//	OTEL_SERVICE_NAME                       (notifications)
+//
+// El exportador otlp usa gRPC y las variables estándar OTEL_EXPORTER_OTLP_* (endpoint, cabeceras, TLS).
+// Con none no se exporta nada, pero el trace ID recibido en traceparent se sigue propagando.
+// Se lee con os.Getenv y no con config para que config pueda usar el plugin de GORM.
+// Devuelve la función que vacía y cierra el exportador al apagar el servicio.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	exporter, err := newExporter(ctx, strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")))
+	if err != nil || exporter == nil {
+		return func(context.Context) error { return nil }, err
+	}
+
+	ratio := 1.0
+	if value := os.Getenv("OTEL_TRACES_SAMPLER_RATIO"); value != "" {
+		if ratio, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("invalid OTEL_TRACES_SAMPLER_RATIO: %w", err)
+		}
+	}
+
+	name := os.Getenv("OTEL_SERVICE_NAME")
+	if name == "" {
+		name = ServiceName
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(name)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Se respeta la decisión de muestreo del productor si la petición ya trae traza
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, name string) (sdktrace.SpanExporter, error) {
+	switch name {
+	case "", "none":
+		return nil, nil
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "otlp":
+		return otlptracegrpc.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q", name)
+	}
+}
+
+// Tracer devuelve el tracer del servicio sobre el TracerProvider global
+func Tracer() trace.Tracer {
+	return otel.Tracer(ServiceName)
+}
+
+// Start abre un span hijo del que tenga ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End cierra el span marcándolo como error si err no es nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceID devuelve el trace ID (32 caracteres hex) del span de ctx, o "" si no hay traza
+func TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"notifications/config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidTimestamp indica que el valor no coincide con ningún formato aceptado
+var ErrInvalidTimestamp = errors.New("invalid timestamp")
+
+// Formatos ISO sin zona, como los de datetime.isoformat() y str(datetime) en Python
+var naiveLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04",
+}
+
+// epochMillisThreshold separa segundos de milisegundos: 1e11 s es el año 5138, 1e11 ms es 1973
+const epochMillisThreshold = 1e11
+
+// ParseTimestamp interpreta el timestamp de un productor y lo devuelve en UTC. Acepta:
+//
+//   - RFC3339 / RFC3339Nano con offset ("2024-01-15T10:30:00.123+02:00", "...Z")
+//   - ISO sin zona de Python ("2024-01-15T10:30:00.123456"), en la zona TIMESTAMP_NAIVE_ZONE (UTC por defecto)
+//   - Unix epoch en segundos ("1705314600", admite decimales) o milisegundos ("1705314600123")
+func ParseTimestamp(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("%w: empty value", ErrInvalidTimestamp)
+	}
+
+	if epoch, err := strconv.ParseFloat(value, 64); err == nil {
+		return parseEpoch(epoch)
+	}
+
+	// RFC3339Nano acepta también segundos sin fracción; se admite espacio en lugar de "T"
+	if t, err := time.Parse(time.RFC3339Nano, strings.Replace(value, " ", "T", 1)); err == nil {
+		return t.UTC(), nil
+	}
+
+	location := naiveLocation()
+	for _, layout := range naiveLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w: %q is not RFC3339, ISO 8601 or Unix epoch", ErrInvalidTimestamp, value)
+}
+
+func parseEpoch(epoch float64) (time.Time, error) {
+	if math.IsNaN(epoch) || math.IsInf(epoch, 0) || epoch < 0 {
+		return time.Time{}, fmt.Errorf("%w: epoch out of range", ErrInvalidTimestamp)
+	}
+	if epoch >= epochMillisThreshold {
+		epoch /= 1000
+	}
+	if epoch >= epochMillisThreshold {
+		return time.Time{}, fmt.Errorf("%w: epoch out of range", ErrInvalidTimestamp)
+	}
+
+	seconds, fraction := math.Modf(epoch)
+	return time.Unix(int64(seconds), int64(math.Round(fraction*1e6))*int64(time.Microsecond)).UTC(), nil
+}
+
+// naiveLocation es la zona en la que se interpretan los timestamps sin offset
+func naiveLocation() *time.Location {
+	name := config.GetEnv("TIMESTAMP_NAIVE_ZONE", "UTC")
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		slog.Warn("Invalid TIMESTAMP_NAIVE_ZONE, using UTC", "zone", name)
+		return time.UTC
+	}
+	return location
+}
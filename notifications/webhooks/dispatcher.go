@@ -0,0 +1,274 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"notifications/clock"
+	"notifications/health"
+	"notifications/lifecycle"
+	"notifications/models"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Headers enviados a los suscriptores
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderID        = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+)
+
+// Sign calcula la firma "sha256=<hex>" de HMAC-SHA256(secret, "<timestamp>.<body>").
+// Incluir el timestamp permite al suscriptor rechazar reenvíos antiguos.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher envía las entregas pendientes con reintentos y backoff exponencial;
+// tras MaxAttempts fallos la entrega pasa a dead_letter
+type Dispatcher struct {
+	DB          *gorm.DB
+	Client      *http.Client
+	Clock       clock.Clock
+	Interval    time.Duration
+	MaxAttempts int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	BatchSize   int
+	// Lease es cuánto se aparta una entrega reclamada antes de que otra réplica pueda tomarla si
+	// esta muere a mitad; por defecto el timeout del cliente más un minuto
+	Lease time.Duration
+	// Heartbeat late en cada vuelta para /readyz; opcional
+	Heartbeat *health.Heartbeat
+}
+
+// Run procesa entregas vencidas en cada tick hasta que ctx se cancele
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := d.Clock.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := d.RunOnce(ctx)
+		if err != nil {
+			slog.Error("Webhook dispatcher error", "error", err)
+		}
+		d.Heartbeat.Beat(err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+type dueDelivery struct {
+	models.WebhookDelivery
+	URL    string    `gorm:"column:url"`
+	Secret string    `gorm:"column:secret"`
+	UserID uuid.UUID `gorm:"column:userId"`
+}
+
+// lifecycleChannel es el canal con el que las entregas de webhook aparecen en el historial
+const lifecycleChannel = "webhook"
+
+// Due cuenta las entregas pendientes ya vencidas (las reclamadas no cuentan mientras dure su lease);
+// alimenta la métrica queue_depth
+func (d *Dispatcher) Due(ctx context.Context) (int64, error) {
+	var n int64
+	err := d.DB.WithContext(ctx).Model(&models.WebhookDelivery{}).
+		Where(`status = ? AND "nextAttemptAt" <= ?`, models.WebhookStatusPending, d.Clock.Now()).
+		Count(&n).Error
+	return n, err
+}
+
+// RunOnce intenta un lote de entregas vencidas y devuelve cuántas tuvieron éxito.
+// El lote se reclama en una transacción corta y las peticiones HTTP se hacen después, fuera de
+// cualquier transacción, así que un suscriptor lento no mantiene bloqueos abiertos.
+func (d *Dispatcher) RunOnce(ctx context.Context) (int, error) {
+	due, err := d.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	succeeded := 0
+	for _, delivery := range due {
+		if d.attempt(ctx, delivery) {
+			succeeded++
+		}
+	}
+	return succeeded, nil
+}
+
+func (d *Dispatcher) lease() time.Duration {
+	if d.Lease > 0 {
+		return d.Lease
+	}
+	return d.Client.Timeout + time.Minute
+}
+
+// leaseUntil se trunca a microsegundos, la precisión de Postgres, para poder compararlo después
+func (d *Dispatcher) leaseUntil() time.Time {
+	return d.Clock.Now().Add(d.lease()).Truncate(time.Microsecond)
+}
+
+// claim reclama un lote con FOR UPDATE SKIP LOCKED y lo aparta moviendo "nextAttemptAt" al final del
+// lease: otras réplicas no lo ven como vencido y, si este proceso muere, vuelve a estar disponible
+// al expirar el lease. El valor de "nextAttemptAt" hace de token del lease.
+func (d *Dispatcher) claim(ctx context.Context) ([]dueDelivery, error) {
+	var due []dueDelivery
+	err := d.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table(`"WebhookDeliveries" AS d`).
+			Select(`d.*, s.url, s.secret, s."userId"`).
+			Joins(`JOIN "WebhookSubscriptions" s ON s.id = d."subscriptionId"`).
+			Where(`d.status = ? AND d."nextAttemptAt" <= ?`, models.WebhookStatusPending, d.Clock.Now()).
+			Order(`d."nextAttemptAt"`).
+			Limit(d.BatchSize).
+			Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "d", Raw: true}, Options: "SKIP LOCKED"}).
+			Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, 0, len(due))
+		for _, delivery := range due {
+			ids = append(ids, delivery.ID)
+		}
+		leaseUntil := d.leaseUntil()
+		if err := tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("nextAttemptAt", leaseUntil).Error; err != nil {
+			return err
+		}
+		for i := range due {
+			due[i].NextAttemptAt = leaseUntil
+		}
+		return nil
+	})
+	return due, err
+}
+
+// renew extiende el lease justo antes de enviar, para que cubra esta petición aunque las anteriores
+// del lote hayan tardado. Devuelve false si el lease expiró y otra réplica tomó la entrega.
+func (d *Dispatcher) renew(ctx context.Context, delivery *dueDelivery) (bool, error) {
+	leaseUntil := d.leaseUntil()
+	result := d.DB.WithContext(ctx).Model(&models.WebhookDelivery{}).
+		Where(`id = ? AND status = ? AND "nextAttemptAt" = ?`, delivery.ID, models.WebhookStatusPending, delivery.NextAttemptAt).
+		Update("nextAttemptAt", leaseUntil)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	delivery.NextAttemptAt = leaseUntil
+	return true, nil
+}
+
+// attempt envía una entrega y registra el resultado en una actualización corta condicionada al
+// lease; devuelve true si el suscriptor respondió 2xx
+func (d *Dispatcher) attempt(ctx context.Context, delivery dueDelivery) bool {
+	if ok, err := d.renew(ctx, &delivery); !ok {
+		if err != nil {
+			slog.Error("Could not renew webhook delivery lease", "delivery_id", delivery.ID, "error", err)
+		}
+		return false
+	}
+
+	now := d.Clock.Now()
+	statusCode, err := d.post(ctx, delivery, now)
+
+	updates := map[string]interface{}{
+		"attempts":       delivery.Attempts + 1,
+		"lastStatusCode": statusCode,
+		"lastError":      "",
+	}
+
+	// El dueño de la suscripción solo ve un error genérico; el original queda en el log
+	ok := err == nil
+	lastError := publicError(statusCode, err)
+	switch {
+	case ok:
+		updates["status"] = models.WebhookStatusSucceeded
+		updates["deliveredAt"] = now
+	case delivery.Attempts+1 >= d.MaxAttempts:
+		updates["status"] = models.WebhookStatusDeadLetter
+		updates["lastError"] = lastError
+		slog.Warn("Webhook delivery moved to dead letter", "delivery_id", delivery.ID, "attempts", delivery.Attempts+1, "error", err)
+	default:
+		updates["lastError"] = lastError
+		updates["nextAttemptAt"] = now.Add(d.backoff(delivery.Attempts + 1))
+		slog.Debug("Webhook delivery failed, will retry", "delivery_id", delivery.ID, "attempts", delivery.Attempts+1, "error", err)
+	}
+
+	// Si el lease expiró durante la petición otra réplica puede tenerla; su resultado prevalece
+	result := d.DB.WithContext(ctx).Model(&models.WebhookDelivery{}).
+		Where(`id = ? AND "nextAttemptAt" = ?`, delivery.ID, delivery.NextAttemptAt).
+		Updates(updates)
+	if result.Error != nil {
+		slog.Error("Could not update webhook delivery", "delivery_id", delivery.ID, "error", result.Error)
+	} else if result.RowsAffected == 0 {
+		slog.Warn("Webhook delivery lease expired before recording the result", "delivery_id", delivery.ID)
+	}
+
+	detail := fmt.Sprintf("subscription %s, status %d", delivery.SubscriptionID, statusCode)
+	if err != nil {
+		detail += ": " + lastError
+	}
+	lifecycle.Delivery(d.DB, delivery.NotificationID, delivery.UserID, lifecycleChannel, lifecycle.Result(err), detail)
+	return ok
+}
+
+func (d *Dispatcher) post(ctx context.Context, delivery dueDelivery, now time.Time) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := now.Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, delivery.ID.String())
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("subscriber responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff devuelve BackoffBase * 2^(attempt-1) con tope BackoffMax y un jitter de hasta el 20%
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	wait := d.BackoffBase
+	for i := 1; i < attempt && wait < d.BackoffMax; i++ {
+		wait *= 2
+	}
+	if wait > d.BackoffMax {
+		wait = d.BackoffMax
+	}
+	return wait + time.Duration(rand.Int63n(int64(wait)/5+1))
+}
@@ -0,0 +1,57 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"notifications/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EventNotificationCreated es el evento enviado por cada notificación guardada
+const EventNotificationCreated = "notification.created"
+
+// Enqueue crea una entrega pendiente por cada suscripción activa del destinatario que acepte
+// el tipo. Se llama dentro de la transacción de creación de la notificación.
+func Enqueue(tx *gorm.DB, noti models.Notification, data map[string]interface{}) error {
+	var subs []models.WebhookSubscription
+	if err := tx.Where(`"userId" = ? AND active = ?`, noti.ResponsibleID, true).Find(&subs).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var deliveries []models.WebhookDelivery
+	for _, sub := range subs {
+		if !sub.Accepts(noti.Type) {
+			continue
+		}
+
+		id := uuid.New()
+		payload, err := json.Marshal(map[string]interface{}{
+			"id":        id,
+			"event":     EventNotificationCreated,
+			"createdAt": now.UTC().Format(time.RFC3339),
+			"data":      data,
+		})
+		if err != nil {
+			return err
+		}
+
+		deliveries = append(deliveries, models.WebhookDelivery{
+			ID:             id,
+			SubscriptionID: sub.ID,
+			NotificationID: noti.ID,
+			Event:          EventNotificationCreated,
+			Payload:        string(payload),
+			Status:         models.WebhookStatusPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		})
+	}
+
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return tx.Create(&deliveries).Error
+}
@@ -0,0 +1,132 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenTarget indica que la URL apunta a una dirección interna (loopback, red privada,
+// link-local o metadatos del proveedor cloud) a la que el servicio no debe hacer peticiones
+var ErrForbiddenTarget = errors.New("webhook target address is not allowed")
+
+// forbiddenPrefixes son los rangos que no son loopback, privados ni link-local según netip
+// pero tampoco son destinos públicos
+var forbiddenPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),         // "esta red"
+	netip.MustParsePrefix("100.64.0.0/10"),     // CGNAT, usado por algunas redes internas de cloud
+	netip.MustParsePrefix("192.0.0.0/24"),      // asignaciones de protocolo de IETF
+	netip.MustParsePrefix("198.18.0.0/15"),     // pruebas de rendimiento
+	netip.MustParsePrefix("240.0.0.0/4"),       // reservado
+	netip.MustParsePrefix("64:ff9b::/96"),      // NAT64: puede traducirse a una IPv4 interna
+	netip.MustParsePrefix("fd00:ec2::254/128"), // metadatos de AWS por IPv6
+}
+
+// ForbiddenAddr indica si la dirección no es un destino público válido para un webhook.
+// 169.254.169.254 (metadatos de AWS, GCP y Azure) está dentro de link-local.
+func ForbiddenAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() {
+		return true
+	}
+	for _, prefix := range forbiddenPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateURL comprueba la URL de una suscripción: https (http solo con allowInsecure), con host
+// y sin credenciales, y que todas las direcciones a las que resuelve sean públicas.
+// allowInsecure (solo desarrollo) permite http y destinos internos.
+func ValidateURL(ctx context.Context, raw string, allowInsecure bool) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.Hostname() == "" {
+		return errors.New("url must be an absolute https URL")
+	}
+	if u.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && allowInsecure:
+	default:
+		return errors.New("url must use https")
+	}
+	if allowInsecure {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("url host %q could not be resolved", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if ForbiddenAddr(addr) {
+			return ErrForbiddenTarget
+		}
+	}
+	return nil
+}
+
+// NewClient crea el cliente HTTP del dispatcher. La dirección se vuelve a comprobar al conectar
+// (Dialer.Control ve la IP ya resuelta), así que un DNS que cambie tras el registro (DNS rebinding)
+// no permite alcanzar destinos internos. No usa proxy ni sigue redirecciones: un 3xx es un fallo.
+func NewClient(timeout time.Duration, allowInsecure bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowInsecure {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if ForbiddenAddr(addrPort.Addr()) {
+				return ErrForbiddenTarget
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// publicError es el texto de error que se guarda en la entrega y ve el dueño de la suscripción.
+// No incluye la respuesta ni el error de red original, que solo se registran en el log.
+func publicError(statusCode int, err error) string {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrForbiddenTarget):
+		return "target address not allowed"
+	case statusCode != 0:
+		return fmt.Sprintf("subscriber responded with status %d", statusCode)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "request timed out"
+	default:
+		return "connection failed"
+	}
+}